@@ -1,78 +1,116 @@
-package main
-
-import (
-	"context"
-	"log"
-	"net/http"
-	"os"
-	"time"
-
-	"github.com/NicoJCastro/gocourse_user/internal/user"
-	"github.com/NicoJCastro/gocourse_user/pkg/bootstrap"
-	"github.com/NicoJCastro/gocourse_user/pkg/handler"
-
-	"github.com/joho/godotenv"
-)
-
-func main() {
-
-	_ = godotenv.Load(".env")
-	//logger
-	logger := bootstrap.InitLogger()
-
-	//db
-	db, err := bootstrap.DBConnection()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	pagLimitDef := os.Getenv("PAGINATION_LIMIT_DEFAUL")
-	if pagLimitDef == "" {
-		logger.Fatal("PAGINATION_LIMIT_DEFAUL is not set")
-	}
-
-	ctx := context.Background()
-
-	userRepo := user.NewRepository(logger, db)
-	userService := user.NewService(logger, userRepo)
-	userEndpoints := user.MakeEndpoints(userService, user.Config{LimPageDef: pagLimitDef})
-
-	h := handler.NewUserHTTPServer(ctx, userEndpoints)
-
-	port := os.Getenv("PORT")
-	adress := "localhost:" + port
-
-	srv := &http.Server{
-		Handler:      accessControl(h),
-		Addr:         adress,
-		WriteTimeout: 5 * time.Second,
-		ReadTimeout:  5 * time.Second,
-	}
-
-	errCh := make(chan error)
-	go func() {
-		logger.Println("listen in ", adress)
-		errCh <- srv.ListenAndServe()
-	}()
-
-	err = <-errCh
-	if err != nil {
-		logger.Println("error: ", err)
-		os.Exit(1)
-	}
-
-}
-
-func accessControl(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, DELETE, OPTIONS, HEAD")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, Cache-Control, X-Requested-With")
-
-		if r.Method == "OPTIONS" {
-			return
-		}
-
-		h.ServeHTTP(w, r)
-	})
-}
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+	"github.com/NicoJCastro/gocourse_user/pkg/bootstrap"
+	"github.com/NicoJCastro/gocourse_user/pkg/handler"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+
+	_ = godotenv.Load(".env")
+	//logger
+	logger := bootstrap.InitLogger()
+
+	pagLimitDef := os.Getenv("PAGINATION_LIMIT_DEFAUL")
+	if pagLimitDef == "" {
+		logger.Fatal("PAGINATION_LIMIT_DEFAUL is not set")
+	}
+
+	ctx := context.Background()
+
+	//repository
+	var userRepo user.Repository
+	switch os.Getenv("REPOSITORY_TYPE") {
+	case "memory":
+		logger.Println("using in-memory repository")
+		userRepo = user.NewMemoryRepository(logger)
+	case "", "gorm":
+		db, err := bootstrap.DBConnection()
+		if err != nil {
+			log.Fatal(err)
+		}
+		userRepo = user.NewRepository(logger, db)
+	default:
+		logger.Fatalf("REPOSITORY_TYPE %q is not supported", os.Getenv("REPOSITORY_TYPE"))
+	}
+
+	userService := user.NewService(logger, userRepo, user.ServiceConfig{
+		DefaultCountry: os.Getenv("PHONE_DEFAULT_COUNTRY"),
+		EmailPolicy:    os.Getenv("EMAIL_CANONICAL_POLICY"),
+	})
+	// Cuánto se recuerdan las Idempotency-Key de POST /users (ej: 24h)
+	var idempotencyTTL time.Duration
+	if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl <= 0 {
+			logger.Fatalf("IDEMPOTENCY_TTL %q is not a valid duration", value)
+		}
+		idempotencyTTL = ttl
+	}
+	// Cuántas Idempotency-Key se recuerdan como máximo; al llenarse se descartan las usadas hace más tiempo
+	var idempotencyMaxKeys int
+	if value := os.Getenv("IDEMPOTENCY_MAX_KEYS"); value != "" {
+		maxKeys, err := strconv.Atoi(value)
+		if err != nil || maxKeys <= 0 {
+			logger.Fatalf("IDEMPOTENCY_MAX_KEYS %q is not a valid number", value)
+		}
+		idempotencyMaxKeys = maxKeys
+	}
+
+	userEndpoints := user.MakeEndpoints(userService, user.Config{
+		LimPageDef:         pagLimitDef,
+		RequireIfMatch:     os.Getenv("REQUIRE_IF_MATCH") == "true",
+		IdempotencyTTL:     idempotencyTTL,
+		IdempotencyMaxKeys: idempotencyMaxKeys,
+	})
+
+	h := handler.NewUserHTTPServer(ctx, userEndpoints)
+
+	port := os.Getenv("PORT")
+	adress := "localhost:" + port
+
+	srv := &http.Server{
+		Handler:      accessControl(h),
+		Addr:         adress,
+		WriteTimeout: 5 * time.Second,
+		ReadTimeout:  5 * time.Second,
+	}
+
+	errCh := make(chan error)
+	go func() {
+		logger.Println("listen in ", adress)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	err := <-errCh
+	if err != nil {
+		logger.Println("error: ", err)
+		os.Exit(1)
+	}
+
+}
+
+func accessControl(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, DELETE, OPTIONS, HEAD")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, Cache-Control, X-Requested-With, If-Match, If-None-Match, If-Modified-Since, Idempotency-Key, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Idempotent-Replayed, X-Request-ID")
+
+		if r.Method == "OPTIONS" {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+// Cursor es la posición del último usuario visto en la paginación por keyset (created_at desc, id desc)
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// NewCursor arma el cursor que apunta justo después de u
+func NewCursor(u domain.User) Cursor {
+	return Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// Encode devuelve el cursor como un token opaco para el cliente
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor interpreta el token recibido en el parámetro cursor
+func DecodeCursor(token string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,30 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, time.March, 10, 8, 30, 15, 123456789, time.UTC)
+	c := NewCursor(domain.User{ID: "0b7e6f3c-1c47-4a43-9a7e-2d1f0f1f2a3b", CreatedAt: created})
+
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if got.ID != c.ID || !got.CreatedAt.Equal(created) {
+		t.Fatalf("DecodeCursor = %+v, want %+v", got, c)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, token := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q): expected ErrInvalidCursor, got %v", token, err)
+		}
+	}
+}
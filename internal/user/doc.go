@@ -0,0 +1,10 @@
+// Package user implementa el servicio de usuarios: endpoints, servicio, repositorios (GORM y memoria)
+// y los errores y mensajes que ve el cliente.
+//
+// 💡 Idempotency-Key (POST /users) se recuerda en memoria, en cada instancia: las réplicas no comparten
+// las keys y un reinicio las pierde, así que un reintento que llegue a otra instancia (o después de
+// reiniciar) puede crear el usuario otra vez. Para correr varias réplicas hace falta un balanceo que
+// mantenga a cada cliente en la misma instancia. Además se recuerdan como máximo
+// Config.IdempotencyMaxKeys keys: al llenarse se descartan las respuestas usadas hace más tiempo
+// y, si todas las keys están en curso, las nuevas reciben 503 hasta que se libere alguna.
+package user
@@ -0,0 +1,64 @@
+package user
+
+import (
+	"strings"
+)
+
+// Políticas de canonicalización de emails (ServiceConfig.EmailPolicy)
+const (
+	// EmailPolicyBasic quita espacios y pasa el dominio a minúsculas (por defecto)
+	EmailPolicyBasic = "basic"
+	// EmailPolicyProvider además quita los puntos y los +tags en los proveedores que los ignoran
+	EmailPolicyProvider = "provider"
+)
+
+// emailProvider describe cómo trata un proveedor la parte local de sus direcciones
+type emailProvider struct {
+	domain     string // dominio canónico
+	stripDots  bool
+	stripTags  bool
+	ignoreCase bool
+}
+
+var emailProviders = map[string]emailProvider{
+	"gmail.com":      {domain: "gmail.com", stripDots: true, stripTags: true, ignoreCase: true},
+	"googlemail.com": {domain: "gmail.com", stripDots: true, stripTags: true, ignoreCase: true},
+	"outlook.com":    {domain: "outlook.com", stripTags: true, ignoreCase: true},
+	"hotmail.com":    {domain: "hotmail.com", stripTags: true, ignoreCase: true},
+	"live.com":       {domain: "live.com", stripTags: true, ignoreCase: true},
+	"icloud.com":     {domain: "icloud.com", stripTags: true, ignoreCase: true},
+	"fastmail.com":   {domain: "fastmail.com", stripTags: true, ignoreCase: true},
+	"proton.me":      {domain: "proton.me", stripTags: true, ignoreCase: true},
+	"protonmail.com": {domain: "protonmail.com", stripTags: true, ignoreCase: true},
+}
+
+// canonicalizeEmail normaliza un email según la política configurada.
+// Si no tiene la forma local@dominio solo se recorta, y la validación lo reportará.
+func canonicalizeEmail(email, policy string) string {
+	email = strings.TrimSpace(email)
+
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return email
+	}
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+
+	if policy == EmailPolicyProvider {
+		if p, ok := emailProviders[domain]; ok {
+			domain = p.domain
+			if p.stripTags {
+				if plus := strings.Index(local, "+"); plus > 0 {
+					local = local[:plus]
+				}
+			}
+			if p.stripDots {
+				local = strings.ReplaceAll(local, ".", "")
+			}
+			if p.ignoreCase {
+				local = strings.ToLower(local)
+			}
+		}
+	}
+
+	return local + "@" + domain
+}
@@ -0,0 +1,27 @@
+package user
+
+import "testing"
+
+func TestCanonicalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, policy string
+		want       string
+	}{
+		{"  Ana.Gomez@Example.COM ", EmailPolicyBasic, "Ana.Gomez@example.com"},
+		{"Ana.Gomez+news@GMail.com", EmailPolicyBasic, "Ana.Gomez+news@gmail.com"},
+		{"Ana.Gomez+news@GMail.com", "", "Ana.Gomez+news@gmail.com"},
+		{"Ana.Gomez+news@GMail.com", EmailPolicyProvider, "anagomez@gmail.com"},
+		{"a.n.a@googlemail.com", EmailPolicyProvider, "ana@gmail.com"},
+		{"Ana.Gomez+work@outlook.com", EmailPolicyProvider, "ana.gomez@outlook.com"},
+		{"Ana.Gomez+work@example.com", EmailPolicyProvider, "Ana.Gomez+work@example.com"},
+		{"+tag@gmail.com", EmailPolicyProvider, "+tag@gmail.com"},
+		{"not-an-email", EmailPolicyProvider, "not-an-email"},
+		{"trailing@", EmailPolicyProvider, "trailing@"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalizeEmail(tt.in, tt.policy); got != tt.want {
+			t.Errorf("canonicalizeEmail(%q, %q) = %q, want %q", tt.in, tt.policy, got, tt.want)
+		}
+	}
+}
@@ -1,44 +1,267 @@
-package user
-
-import (
-	"errors"
-	"fmt"
-)
-
-var ErrFirstNameRequired = errors.New("first name is required")
-var ErrLastNameRequired = errors.New("last name is required")
-var ErrEmailRequired = errors.New("email is required")
-var ErrPhoneRequired = errors.New("phone is required")
-var ErrUserAlreadyExists = errors.New("user already exists")
-var ErrUserNotUpdated = errors.New("user not updated")
-var ErrUserNotDeleted = errors.New("user not deleted")
-var ErrUserNotCreated = errors.New("user not created")
-var ErrUserNotRetrieved = errors.New("user not retrieved")
-var ErrUserNotCounted = errors.New("user not counted")
-var ErrInvalidRequestType = errors.New("invalid request type")
-var ErrInvalidDefaultLimitConfiguration = errors.New("invalid default limit configuration")
-var ErrIDRequired = errors.New("id is required")
-var ErrAtLeastOneFieldRequired = errors.New("at least one field is required")
-
-// ErrNotFound es un error personalizado que incluye el ID del usuario no encontrado
-type ErrNotFound struct {
-	UserID string
-}
-
-// Error implementa la interfaz error
-func (e *ErrNotFound) Error() string {
-	return fmt.Sprintf("user with ID %s not found", e.UserID)
-}
-
-// Unwrap permite usar errors.Is() con este error
-func (e *ErrNotFound) Unwrap() error {
-	return ErrNotFoundBase
-}
-
-// NewErrNotFound crea una nueva instancia de ErrNotFound
-func NewErrNotFound(userID string) *ErrNotFound {
-	return &ErrNotFound{UserID: userID}
-}
-
-// ErrNotFoundBase es un error sentinela para comparaciones con errors.Is()
-var ErrNotFoundBase = errors.New("user not found")
+package user
+
+import (
+	"errors"
+)
+
+var ErrFirstNameRequired = errors.New("first name is required")
+var ErrLastNameRequired = errors.New("last name is required")
+var ErrEmailRequired = errors.New("email is required")
+var ErrPhoneRequired = errors.New("phone is required")
+var ErrUserAlreadyExists = errors.New("user already exists")
+var ErrUserNotUpdated = errors.New("user not updated")
+var ErrUserNotDeleted = errors.New("user not deleted")
+var ErrUserNotRestored = errors.New("user not restored")
+var ErrUserNotInTrash = errors.New("user is not deleted: only deleted users can be restored")
+var ErrUserNotCreated = errors.New("user not created")
+var ErrUserNotRetrieved = errors.New("user not retrieved")
+var ErrUserNotCounted = errors.New("user not counted")
+var ErrInvalidRequestType = errors.New("invalid request type")
+var ErrInvalidBody = errors.New("invalid request body")
+var ErrInvalidParameter = errors.New("invalid parameter")
+var ErrInvalidDefaultLimitConfiguration = errors.New("invalid default limit configuration")
+var ErrIDRequired = errors.New("id is required")
+var ErrAtLeastOneFieldRequired = errors.New("at least one field is required")
+var ErrFirstNameEmpty = errors.New("first name cannot be empty")
+var ErrLastNameEmpty = errors.New("last name cannot be empty")
+var ErrEmailEmpty = errors.New("email cannot be empty")
+var ErrPhoneEmpty = errors.New("phone cannot be empty")
+var ErrValidationFailed = errors.New("validation failed")
+var ErrInvalidSortField = errors.New("invalid sort field")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidFilterParam = errors.New("invalid filter")
+var ErrCursorWithSort = errors.New("cursor pagination only supports the default order")
+var ErrBatchEmpty = errors.New("batch must contain at least one user")
+var ErrBatchTooLarge = errors.New("batch is too large")
+var ErrBatchAborted = errors.New("batch aborted: another user in the batch failed")
+var ErrSelectionRequired = errors.New("ids or at least one filter is required")
+var ErrBulkEmailUpdate = errors.New("email cannot be updated in bulk")
+var ErrInvalidPatch = errors.New("invalid patch")
+var ErrPatchTestFailed = errors.New("patch test failed")
+var ErrVersionMismatch = errors.New("user was modified by another request: fetch it again and retry")
+var ErrIfMatchRequired = errors.New("If-Match header is required")
+var ErrIfMatchList = errors.New("If-Match must contain a single ETag")
+var ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request")
+var ErrIdempotencyKeysBusy = errors.New("too many requests with an Idempotency-Key in progress: retry later")
+
+// ErrNotFound es un error personalizado que incluye el ID (o el email) del usuario no encontrado
+type ErrNotFound struct {
+	UserID string
+	Email  string
+}
+
+// Error implementa la interfaz error
+func (e *ErrNotFound) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrNotFound) message() message {
+	if e.Email != "" {
+		return newMessage("user with email %s not found", e.Email)
+	}
+	return newMessage("user with ID %s not found", e.UserID)
+}
+
+// Unwrap permite usar errors.Is() con este error
+func (e *ErrNotFound) Unwrap() error {
+	return ErrNotFoundBase
+}
+
+// NewErrNotFound crea una nueva instancia de ErrNotFound
+func NewErrNotFound(userID string) *ErrNotFound {
+	return &ErrNotFound{UserID: userID}
+}
+
+// NewErrEmailNotFound crea un ErrNotFound para una búsqueda por email
+func NewErrEmailNotFound(email string) *ErrNotFound {
+	return &ErrNotFound{Email: email}
+}
+
+// ErrNotFoundBase es un error sentinela para comparaciones con errors.Is()
+var ErrNotFoundBase = errors.New("user not found")
+
+// ErrAlreadyExists indica qué campo del usuario entra en conflicto con otro existente
+type ErrAlreadyExists struct {
+	Field string
+	Value string
+}
+
+// Error implementa la interfaz error
+func (e *ErrAlreadyExists) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrAlreadyExists) message() message {
+	return newMessage("user with %s %s already exists", e.Field, e.Value)
+}
+
+// Unwrap permite usar errors.Is() con ErrUserAlreadyExists
+func (e *ErrAlreadyExists) Unwrap() error {
+	return ErrUserAlreadyExists
+}
+
+// NewErrAlreadyExists crea una nueva instancia de ErrAlreadyExists
+func NewErrAlreadyExists(field, value string) *ErrAlreadyExists {
+	return &ErrAlreadyExists{Field: field, Value: value}
+}
+
+// ErrInvalidSort indica un campo de orden desconocido o mal formado
+type ErrInvalidSort struct {
+	Field string
+}
+
+// Error implementa la interfaz error
+func (e *ErrInvalidSort) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrInvalidSort) message() message {
+	return newMessage("%s: %q", newMessage(ErrInvalidSortField.Error()), e.Field)
+}
+
+// Unwrap permite usar errors.Is() con ErrInvalidSortField
+func (e *ErrInvalidSort) Unwrap() error {
+	return ErrInvalidSortField
+}
+
+// NewErrInvalidSort crea una nueva instancia de ErrInvalidSort
+func NewErrInvalidSort(field string) *ErrInvalidSort {
+	return &ErrInvalidSort{Field: field}
+}
+
+// ErrInvalidFilter indica un filtro con operador mal formado (ej: email[like]=...)
+type ErrInvalidFilter struct {
+	Param  string
+	Reason string
+}
+
+// Error implementa la interfaz error
+func (e *ErrInvalidFilter) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrInvalidFilter) message() message {
+	return newMessage("%s %s: %s", newMessage(ErrInvalidFilterParam.Error()), e.Param, newMessage(e.Reason))
+}
+
+// Unwrap permite usar errors.Is() con ErrInvalidFilterParam
+func (e *ErrInvalidFilter) Unwrap() error {
+	return ErrInvalidFilterParam
+}
+
+// NewErrInvalidFilter crea una nueva instancia de ErrInvalidFilter
+func NewErrInvalidFilter(param, reason string) *ErrInvalidFilter {
+	return &ErrInvalidFilter{Param: param, Reason: reason}
+}
+
+// ErrParameter indica un parámetro del query string o un header con un valor inválido
+type ErrParameter struct {
+	Name   string
+	Reason string
+	// reason es Reason sin formatear, para traducirlo
+	reason message
+}
+
+// Error implementa la interfaz error
+func (e *ErrParameter) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrParameter) message() message {
+	reason := e.reason
+	if reason.format == "" {
+		reason = newMessage(e.Reason)
+	}
+	return newMessage("%s %s: %s", newMessage(ErrInvalidParameter.Error()), e.Name, reason)
+}
+
+// Unwrap permite usar errors.Is() con ErrInvalidParameter
+func (e *ErrParameter) Unwrap() error {
+	return ErrInvalidParameter
+}
+
+// NewErrParameter crea una nueva instancia de ErrParameter; reason puede tener verbos de fmt con sus args
+func NewErrParameter(name, reason string, args ...interface{}) *ErrParameter {
+	msg := newMessage(reason, args...)
+	return &ErrParameter{Name: name, Reason: msg.String(), reason: msg}
+}
+
+// ErrBatchSize indica un lote que supera MaxBatchSize
+type ErrBatchSize struct {
+	Size int
+}
+
+// Error implementa la interfaz error
+func (e *ErrBatchSize) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrBatchSize) message() message {
+	return newMessage("%s: %s", newMessage(ErrBatchTooLarge.Error()), newMessage("%d users, the maximum is %d", e.Size, MaxBatchSize))
+}
+
+// Unwrap permite usar errors.Is() con ErrBatchTooLarge
+func (e *ErrBatchSize) Unwrap() error {
+	return ErrBatchTooLarge
+}
+
+// NewErrBatchSize crea una nueva instancia de ErrBatchSize
+func NewErrBatchSize(size int) *ErrBatchSize {
+	return &ErrBatchSize{Size: size}
+}
+
+// ErrPatchOp indica una operación de patch inválida; Index es su posición en el documento
+type ErrPatchOp struct {
+	Index  int
+	Op     string
+	Path   string
+	Reason string
+}
+
+// Error implementa la interfaz error
+func (e *ErrPatchOp) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrPatchOp) message() message {
+	reason := newMessage(e.Reason)
+	if e.Op == "" && e.Path == "" {
+		return newMessage("%s: %s", newMessage(ErrInvalidPatch.Error()), reason)
+	}
+	return newMessage("%s: %s", newMessage(ErrInvalidPatch.Error()), newMessage("operation %d (%s %s): %s", e.Index, e.Op, e.Path, reason))
+}
+
+// Unwrap permite usar errors.Is() con ErrInvalidPatch
+func (e *ErrPatchOp) Unwrap() error {
+	return ErrInvalidPatch
+}
+
+// NewErrPatchOp crea una nueva instancia de ErrPatchOp
+func NewErrPatchOp(index int, op, path, reason string) *ErrPatchOp {
+	return &ErrPatchOp{Index: index, Op: op, Path: path, Reason: reason}
+}
+
+// ErrPatchTest indica que falló una operación test de JSON Patch: el usuario no tiene el valor esperado
+type ErrPatchTest struct {
+	Index int
+	Path  string
+}
+
+// Error implementa la interfaz error
+func (e *ErrPatchTest) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrPatchTest) message() message {
+	return newMessage("%s: %s", newMessage(ErrPatchTestFailed.Error()), newMessage("operation %d: %s does not have the expected value", e.Index, e.Path))
+}
+
+// Unwrap permite usar errors.Is() con ErrPatchTestFailed
+func (e *ErrPatchTest) Unwrap() error {
+	return ErrPatchTestFailed
+}
+
+// NewErrPatchTest crea una nueva instancia de ErrPatchTest
+func NewErrPatchTest(index int, path string) *ErrPatchTest {
+	return &ErrPatchTest{Index: index, Path: path}
+}
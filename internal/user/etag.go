@@ -0,0 +1,125 @@
+package user
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+// Version identifica una versión de un usuario: su updated_at en milisegundos.
+// Es la menor precisión de los motores soportados (MySQL guarda datetime(3)).
+// 💡 Los repositorios escriben siempre updated_at con next, así toda escritura
+// (también sin If-Match, las masivas y Restore) deja una versión nueva.
+type Version int64
+
+// VersionOf devuelve la versión actual del usuario
+func VersionOf(u domain.User) Version {
+	return Version(u.UpdatedAt.UnixMilli())
+}
+
+// ETag devuelve la versión como ETag fuerte de la respuesta en lang, ej: "1704110400123-es".
+// 🌐 El message del body depende de Accept-Language: cada idioma es otra representación y lleva
+// su propio ETag, así un cache nunca responde 304 con el body de otro idioma.
+func (v Version) ETag(lang string) string {
+	return `"` + strconv.FormatInt(int64(v), 10) + "-" + lang + `"`
+}
+
+// Time devuelve el inicio del milisegundo de la versión
+func (v Version) Time() time.Time {
+	return time.UnixMilli(int64(v)).UTC()
+}
+
+// next devuelve el updated_at de la escritura que reemplaza a esta versión:
+// now, salvo que caiga en el mismo milisegundo (o antes), para que la versión siempre avance
+func (v Version) next(now time.Time) time.Time {
+	if earliest := v.Time().Add(time.Millisecond); now.Before(earliest) {
+		return earliest.In(now.Location())
+	}
+	return now
+}
+
+// Precondition es el header If-Match de una escritura.
+// Any corresponde a "If-Match: *": alcanza con que el usuario exista.
+type Precondition struct {
+	Any     bool
+	Version Version
+}
+
+// ParseIfMatch interpreta el header If-Match; devuelve nil si no vino.
+// Un ETag que no generó este servicio (o uno débil) nunca coincide: se devuelve ErrVersionMismatch.
+// 💡 If-Match compara la versión del usuario, no la representación: sirve el ETag de cualquier idioma.
+func ParseIfMatch(header string) (*Precondition, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+	if header == "*" {
+		return &Precondition{Any: true}, nil
+	}
+	if strings.Contains(header, ",") {
+		return nil, ErrIfMatchList
+	}
+
+	value, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		value, ok = strings.CutSuffix(value, `"`)
+	}
+	value, lang, tagged := strings.Cut(value, "-")
+	if _, known := catalogs[lang]; tagged && !known {
+		return nil, ErrVersionMismatch
+	}
+	version, err := strconv.ParseInt(value, 10, 64)
+	if !ok || err != nil {
+		return nil, ErrVersionMismatch
+	}
+	return &Precondition{Version: Version(version)}, nil
+}
+
+// version devuelve la versión que exige la precondición, o nil si no exige ninguna
+func (p *Precondition) version() *Version {
+	if p == nil || p.Any {
+		return nil
+	}
+	return &p.Version
+}
+
+// CacheCondition son los headers de un GET condicional (If-None-Match / If-Modified-Since)
+type CacheCondition struct {
+	IfNoneMatch     string
+	IfModifiedSince *time.Time
+}
+
+// NotModified indica si el cliente ya tiene la representación actual (RFC 9110, 13.2.2):
+// If-None-Match, si vino, tiene prioridad sobre If-Modified-Since.
+// etag es el de la respuesta en el idioma pedido: el ETag de otro idioma no coincide.
+func (c CacheCondition) NotModified(etag string, lastModified time.Time) bool {
+	if c.IfNoneMatch != "" {
+		for _, tag := range strings.Split(c.IfNoneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			// Comparación débil: W/"x" coincide con "x"
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if c.IfModifiedSince == nil || lastModified.IsZero() {
+		return false
+	}
+	// Last-Modified tiene precisión de segundos
+	return !lastModified.Truncate(time.Second).After(*c.IfModifiedSince)
+}
+
+// CollectionETag es el validador de un listado en lang: cambia si cambia la cantidad de usuarios
+// que cumplen los filtros o el updated_at más reciente entre ellos, y es distinto en cada idioma
+func CollectionETag(count int64, lastModified time.Time, lang string) string {
+	var newest int64
+	if !lastModified.IsZero() {
+		newest = lastModified.UnixMilli()
+	}
+	return fmt.Sprintf(`W/"%d-%d-%s"`, count, newest, lang)
+}
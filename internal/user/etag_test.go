@@ -0,0 +1,112 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version := VersionOf(domain.User{UpdatedAt: time.Date(2024, time.January, 1, 12, 0, 0, 123456789, time.UTC)})
+	if got := version.ETag("es"); got != `"1704110400123-es"` {
+		t.Fatalf("ETag = %s", got)
+	}
+
+	tests := []struct {
+		header  string
+		want    *Precondition
+		wantErr error
+	}{
+		{header: "", want: nil},
+		{header: "*", want: &Precondition{Any: true}},
+		{header: ` "1704110400123" `, want: &Precondition{Version: version}},
+		// El ETag de cualquier idioma identifica la misma versión
+		{header: `"1704110400123-en"`, want: &Precondition{Version: version}},
+		{header: `"1704110400123-es"`, want: &Precondition{Version: version}},
+		{header: `"1704110400123-xx"`, wantErr: ErrVersionMismatch},
+		{header: `"-1704110400123"`, wantErr: ErrVersionMismatch},
+		{header: `W/"1704110400123"`, wantErr: ErrVersionMismatch},
+		{header: `"abc"`, wantErr: ErrVersionMismatch},
+		{header: `1704110400123`, wantErr: ErrVersionMismatch},
+		{header: `"1", "2"`, wantErr: ErrIfMatchList},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIfMatch(tt.header)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseIfMatch(%q): expected %v, got %v", tt.header, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIfMatch(%q): unexpected error %v", tt.header, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("ParseIfMatch(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestVersionNext(t *testing.T) {
+	v := Version(1704110400123)
+
+	// Una escritura en el mismo milisegundo igual avanza la versión
+	same := time.UnixMilli(1704110400123).Add(500 * time.Microsecond)
+	if got := Version(v.next(same).UnixMilli()); got != v+1 {
+		t.Fatalf("next in the same millisecond = %d, want %d", got, v+1)
+	}
+
+	later := time.UnixMilli(1704110400999)
+	if got := v.next(later); !got.Equal(later) {
+		t.Fatalf("next = %v, want %v", got, later)
+	}
+}
+
+func TestCacheConditionNotModified(t *testing.T) {
+	lastModified := time.Date(2024, time.January, 1, 12, 0, 0, 500000000, time.UTC)
+	etag := `"1704110400500-es"`
+	same := lastModified.Truncate(time.Second)
+	before := same.Add(-time.Second)
+
+	tests := []struct {
+		name string
+		cond CacheCondition
+		want bool
+	}{
+		{name: "without headers", cond: CacheCondition{}, want: false},
+		{name: "matching etag", cond: CacheCondition{IfNoneMatch: etag}, want: true},
+		{name: "weak comparison", cond: CacheCondition{IfNoneMatch: `W/"1704110400500-es"`}, want: true},
+		{name: "etag in list", cond: CacheCondition{IfNoneMatch: `"1", "1704110400500-es"`}, want: true},
+		// 🌐 Misma versión en otro idioma: el cliente tiene otro body
+		{name: "etag in another language", cond: CacheCondition{IfNoneMatch: `"1704110400500-en"`}, want: false},
+		{name: "wildcard", cond: CacheCondition{IfNoneMatch: "*"}, want: true},
+		{name: "other etag", cond: CacheCondition{IfNoneMatch: `"1"`}, want: false},
+		{name: "same second", cond: CacheCondition{IfModifiedSince: &same}, want: true},
+		{name: "modified since", cond: CacheCondition{IfModifiedSince: &before}, want: false},
+		// If-None-Match tiene prioridad aunque la fecha coincida
+		{name: "etag wins over date", cond: CacheCondition{IfNoneMatch: `"1"`, IfModifiedSince: &same}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cond.NotModified(etag, lastModified); got != tt.want {
+			t.Errorf("%s: NotModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectionETag(t *testing.T) {
+	lastModified := time.Date(2024, time.January, 1, 12, 0, 0, 123000000, time.UTC)
+	if got := CollectionETag(3, lastModified, "es"); got != `W/"3-1704110400123-es"` {
+		t.Fatalf("CollectionETag = %s", got)
+	}
+	if got := CollectionETag(3, lastModified, "en"); got == CollectionETag(3, lastModified, "es") {
+		t.Fatalf("CollectionETag is the same in every language: %s", got)
+	}
+	if got := CollectionETag(0, time.Time{}, "en"); got != `W/"0-0-en"` {
+		t.Fatalf("CollectionETag on empty list = %s", got)
+	}
+}
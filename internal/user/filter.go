@@ -0,0 +1,104 @@
+package user
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FilterOp es el operador de un filtro con sintaxis campo[op]=valor en GET /users
+type FilterOp string
+
+const (
+	// OpContains es LIKE '%valor%' (el comportamiento de los filtros sin operador)
+	OpContains FilterOp = "contains"
+	// OpPrefix es LIKE 'valor%'
+	OpPrefix FilterOp = "prefix"
+	// OpEq es igualdad exacta sin distinguir mayúsculas
+	OpEq FilterOp = "eq"
+	// OpIn es igualdad contra una lista de valores separados por coma
+	OpIn FilterOp = "in"
+)
+
+var filterOps = map[FilterOp]bool{OpContains: true, OpPrefix: true, OpEq: true, OpIn: true}
+
+// filterableColumns son los campos (nombre JSON) que aceptan operadores y su columna
+var filterableColumns = map[string]string{
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"email":      "email",
+	"phone":      "phone",
+}
+
+// FilterFields devuelve, ordenados, los campos que aceptan filtros con operador
+func FilterFields() []string {
+	fields := make([]string, 0, len(filterableColumns))
+	for field := range filterableColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// FilterOps devuelve, ordenados, los operadores de los filtros
+func FilterOps() []FilterOp {
+	ops := make([]FilterOp, 0, len(filterOps))
+	for op := range filterOps {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+// Condition es un filtro con operador, ej: email[eq]=bob@x.com o phone[in]=a,b
+type Condition struct {
+	Field  string
+	Op     FilterOp
+	Values []string
+}
+
+var filterParam = regexp.MustCompile(`^([a-z_]+)\[([a-z]+)\]$`)
+
+// ParseCondition interpreta un parámetro de query con sintaxis campo[op].
+// Devuelve nil si key no usa esa sintaxis, y ErrInvalidFilter si el campo, el operador o el valor no son válidos.
+func ParseCondition(key, value string) (*Condition, error) {
+	m := filterParam.FindStringSubmatch(key)
+	if m == nil {
+		return nil, nil
+	}
+
+	field, op := m[1], FilterOp(m[2])
+	if _, ok := filterableColumns[field]; !ok {
+		return nil, NewErrInvalidFilter(key, "unknown field")
+	}
+	if !filterOps[op] {
+		return nil, NewErrInvalidFilter(key, "unknown operator")
+	}
+
+	values := []string{value}
+	if op == OpIn {
+		values = values[:0]
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 || values[0] == "" {
+		return nil, NewErrInvalidFilter(key, "value is required")
+	}
+
+	return &Condition{Field: field, Op: op, Values: values}, nil
+}
+
+// filterColumn devuelve la columna de un campo filtrable o ErrInvalidFilter si no está permitido
+func filterColumn(c Condition) (string, error) {
+	column, ok := filterableColumns[c.Field]
+	if !ok {
+		return "", NewErrInvalidFilter(c.Field, "unknown field")
+	}
+	if !filterOps[c.Op] {
+		return "", NewErrInvalidFilter(c.Field, "unknown operator")
+	}
+	return column, nil
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       *Condition
+		wantErr    bool
+	}{
+		{key: "email", value: "bob@x.com", want: nil},
+		{key: "limit", value: "10", want: nil},
+		{key: "email[eq]", value: "bob@x.com", want: &Condition{Field: "email", Op: OpEq, Values: []string{"bob@x.com"}}},
+		{key: "last_name[prefix]", value: "Gó", want: &Condition{Field: "last_name", Op: OpPrefix, Values: []string{"Gó"}}},
+		{key: "first_name[contains]", value: "an", want: &Condition{Field: "first_name", Op: OpContains, Values: []string{"an"}}},
+		{key: "phone[in]", value: " +5411, ,+5412 ", want: &Condition{Field: "phone", Op: OpIn, Values: []string{"+5411", "+5412"}}},
+		{key: "password[eq]", value: "x", wantErr: true},
+		{key: "email[like]", value: "x", wantErr: true},
+		{key: "email[eq]", value: "", wantErr: true},
+		{key: "phone[in]", value: " , ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCondition(tt.key, tt.value)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidFilterParam) {
+				t.Errorf("ParseCondition(%q, %q): expected ErrInvalidFilterParam, got %v", tt.key, tt.value, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCondition(%q, %q) = %+v, %v; want %+v", tt.key, tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -1,249 +1,609 @@
-package user
-
-import (
-	"context"
-	"errors"
-	"strconv"
-
-	"github.com/NicoJCastro/go_lib_response/response"
-	"github.com/NicoJCastro/gocourse_meta/meta"
-)
-
-type (
-	Controller func(ctx context.Context, request interface{}) (interface{}, error)
-
-	Endpoint struct {
-		Create Controller
-		Get    Controller
-		GetAll Controller
-		Update Controller
-		Delete Controller
-	}
-
-	CreateRequest struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Email     string `json:"email"`
-		Phone     string `json:"phone"`
-	}
-
-	GetRequest struct {
-		ID string `json:"id"`
-	}
-
-	DeleteRequest struct {
-		ID string `json:"id"`
-	}
-
-	GetAllRequest struct {
-		FirstName string
-		LastName  string
-		Email     string
-		Phone     string
-		Limit     int
-		Page      int
-	}
-
-	UpdateRequest struct {
-		ID        string  `json:"id"`
-		FirstName *string `json:"first_name"`
-		LastName  *string `json:"last_name"`
-		Email     *string `json:"email"`
-		Phone     *string `json:"phone"`
-	}
-
-	Response struct {
-		Status int         `json:"status"`
-		Data   interface{} `json:"data,omitempty"`
-		Err    string      `json:"error,omitempty"`
-		Meta   *meta.Meta  `json:"meta,omitempty"`
-	}
-
-	Config struct {
-		LimPageDef string
-	}
-)
-
-func MakeEndpoints(s Service, config Config) Endpoint {
-	return Endpoint{
-		Create: makeCreateEndpoint(s),
-		Get:    makeGetEndpoint(s),
-		GetAll: makeGetAllEndpoint(s, config),
-		Update: makeUpdateEndpoint(s),
-		Delete: makeDeleteEndpoint(s),
-	}
-}
-
-func makeCreateEndpoint(s Service) Controller {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req, ok := request.(CreateRequest)
-
-		if !ok {
-			return nil, response.BadRequest("invalid request type")
-		}
-
-		if req.FirstName == "" || req.LastName == "" || req.Email == "" || req.Phone == "" {
-			return nil, response.BadRequest("all fields are required")
-		}
-
-		user, err := s.Create(ctx, req.FirstName, req.LastName, req.Email, req.Phone)
-		if err != nil {
-			// 💥 Para errores de creación (BD, validación, etc.)
-			return nil, response.InternalServerError("error creating user: " + err.Error())
-		}
-
-		return response.Created("User created successfully", user, nil), nil
-	}
-}
-
-func makeGetEndpoint(s Service) Controller {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req, ok := request.(GetRequest)
-		if !ok {
-			return nil, response.BadRequest("invalid request type")
-		}
-
-		user, err := s.Get(ctx, req.ID)
-		if err != nil {
-			// 🔍 Verificamos si es un error de "no encontrado"
-			var notFoundErr *ErrNotFound
-			if errors.As(err, &notFoundErr) || errors.Is(err, ErrNotFoundBase) {
-				return nil, response.NotFound(err.Error())
-			}
-			// 💥 Para otros errores (BD, conexión, etc.)
-			return nil, response.InternalServerError("error retrieving user: " + err.Error())
-		}
-
-		return response.OK("User retrieved successfully", user, nil), nil
-	}
-}
-
-func makeGetAllEndpoint(s Service, config Config) Controller {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-
-		v, ok := request.(GetAllRequest)
-		if !ok {
-			return nil, response.BadRequest("invalid request type")
-		}
-
-		filters := Filters{
-			FirstName: v.FirstName,
-			LastName:  v.LastName,
-			Email:     v.Email,
-			Phone:     v.Phone,
-		}
-
-		// Extraemos limit y page directamente del struct GetAllRequest
-		// Si los valores son 0 (no proporcionados), usaremos valores por defecto
-		limit := v.Limit
-		page := v.Page
-
-		// 🔧 Validación: si limit es 0, usamos el valor por defecto de la configuración
-		if limit <= 0 {
-			defaultLimit, err := strconv.Atoi(config.LimPageDef)
-			if err != nil {
-				return nil, response.InternalServerError("invalid default limit configuration")
-			}
-			limit = defaultLimit
-		}
-
-		// 🔧 Validación: si page es 0 o negativo, establecemos página 1
-		if page <= 0 {
-			page = 1
-		}
-
-		count, err := s.Count(ctx, filters)
-		if err != nil {
-			return nil, response.InternalServerError("error counting users: " + err.Error())
-		}
-
-		metaData, err := meta.New(page, limit, int(count), config.LimPageDef)
-		if err != nil {
-			return nil, response.InternalServerError("error generating metadata: " + err.Error())
-		}
-
-		users, err := s.GetAll(ctx, filters, metaData.Offset(), metaData.Limit())
-		if err != nil {
-			return nil, response.InternalServerError("error retrieving users: " + err.Error())
-		}
-
-		return response.OK("Users retrieved successfully", users, metaData), nil
-	}
-}
-
-func makeUpdateEndpoint(s Service) Controller {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req, ok := request.(UpdateRequest)
-		if !ok {
-			return nil, response.BadRequest("invalid request type")
-		}
-
-		// 🎯 Validación: el ID es requerido
-		if req.ID == "" {
-			return nil, response.BadRequest("id is required")
-		}
-
-		// 🎯 Validación: al menos un campo debe ser proporcionado para actualizar
-		if req.FirstName == nil && req.LastName == nil && req.Email == nil && req.Phone == nil {
-			return nil, response.BadRequest("at least one field is required")
-		}
-
-		// 🔧 Validación: si se proporciona un campo, no puede estar vacío
-		if req.FirstName != nil && *req.FirstName == "" {
-			return nil, response.BadRequest("first name cannot be empty")
-		}
-		if req.LastName != nil && *req.LastName == "" {
-			return nil, response.BadRequest("last name cannot be empty")
-		}
-		if req.Email != nil && *req.Email == "" {
-			return nil, response.BadRequest("email cannot be empty")
-		}
-		if req.Phone != nil && *req.Phone == "" {
-			return nil, response.BadRequest("phone cannot be empty")
-		}
-
-		// ✅ Llamamos al servicio y obtenemos el usuario actualizado
-		user, err := s.Update(ctx, req.ID, req.FirstName, req.LastName, req.Email, req.Phone)
-		if err != nil {
-			// 🔍 Verificamos si es un error de "no encontrado"
-			var notFoundErr *ErrNotFound
-			if errors.As(err, &notFoundErr) || errors.Is(err, ErrNotFoundBase) {
-				return nil, response.NotFound(err.Error())
-			}
-			// 💥 Para otros errores (BD, conexión, etc.)
-			return nil, response.InternalServerError("error updating user: " + err.Error())
-		}
-
-		// ✅ Retornamos el usuario actualizado en la respuesta
-		return response.OK("User updated successfully", user, nil), nil
-	}
-}
-
-func makeDeleteEndpoint(s Service) Controller {
-	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req, ok := request.(DeleteRequest)
-		if !ok {
-			return nil, response.BadRequest("invalid request type")
-		}
-
-		// 🎯 Validación: el ID es requerido
-		if req.ID == "" {
-			return nil, response.BadRequest("id is required")
-		}
-
-		// 💡 Llamamos al servicio para eliminar el usuario
-		err := s.Delete(ctx, req.ID)
-		if err != nil {
-			// 🔍 Verificamos si es un error de "no encontrado"
-			var notFoundErr *ErrNotFound
-			if errors.As(err, &notFoundErr) || errors.Is(err, ErrNotFoundBase) {
-				return nil, response.NotFound(err.Error())
-			}
-			// 💥 Para otros errores (BD, conexión, etc.)
-			return nil, response.InternalServerError("error deleting user: " + err.Error())
-		}
-
-		// ✅ Retornamos un mensaje de éxito
-		return response.OK("User deleted successfully", nil, nil), nil
-	}
-}
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_meta/meta"
+)
+
+type (
+	Controller func(ctx context.Context, request interface{}) (interface{}, error)
+
+	Endpoint struct {
+		Create      Controller
+		CreateBatch Controller
+		Get         Controller
+		GetByEmail  Controller
+		GetAll      Controller
+		Update      Controller
+		UpdateBatch Controller
+		Replace     Controller
+		Patch       Controller
+		Delete      Controller
+		DeleteBatch Controller
+		Restore     Controller
+		Errors      Controller
+	}
+
+	CreateRequest struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Phone     string `json:"phone"`
+		// IdempotencyKey es el header Idempotency-Key: los reintentos con la misma key repiten la respuesta
+		IdempotencyKey string `json:"-"`
+	}
+
+	// CreateBatchRequest es el body de POST /users/batch; Atomic viene del query param atomic
+	CreateBatchRequest struct {
+		Items  []CreateRequest
+		Atomic bool
+	}
+
+	GetRequest struct {
+		ID    string         `json:"id"`
+		Cache CacheCondition `json:"-"`
+	}
+
+	GetByEmailRequest struct {
+		Email string `json:"email"`
+	}
+
+	DeleteRequest struct {
+		ID      string        `json:"id"`
+		IfMatch *Precondition `json:"-"`
+		// Hard borra definitivamente en lugar de mandar el usuario a la papelera (?hard=true)
+		Hard bool `json:"-"`
+	}
+
+	RestoreRequest struct {
+		ID string `json:"id"`
+	}
+
+	GetAllRequest struct {
+		IDs       []string
+		FirstName string
+		LastName  string
+		Email     string
+		Phone     string
+		// Query es la búsqueda libre del parámetro q
+		Query string
+		// Conditions son los filtros con operador, ej: email[eq]=bob@x.com
+		Conditions []Condition
+		// Rangos de fechas en RFC 3339 (created_after, created_before, updated_after, updated_before)
+		CreatedAfter  *time.Time
+		CreatedBefore *time.Time
+		UpdatedAfter  *time.Time
+		UpdatedBefore *time.Time
+		Sort          []SortField
+		// Deleted lista la papelera en lugar de los usuarios activos
+		Deleted bool
+		Limit   int
+		Page    int
+		// CursorMode activa la paginación por keyset; Cursor es nil en la primera página
+		CursorMode bool
+		Cursor     *Cursor
+		// Cache son los headers del GET condicional
+		Cache CacheCondition
+	}
+
+	UpdateRequest struct {
+		ID        string        `json:"id,omitempty"`
+		IfMatch   *Precondition `json:"-"`
+		FirstName *string       `json:"first_name"`
+		LastName  *string       `json:"last_name"`
+		Email     *string       `json:"email"`
+		Phone     *string       `json:"phone"`
+	}
+
+	// ReplaceRequest es el body de PUT /users/{id}: el usuario completo, todos los campos son obligatorios
+	ReplaceRequest struct {
+		ID        string        `json:"id,omitempty"`
+		IfMatch   *Precondition `json:"-"`
+		FirstName string        `json:"first_name"`
+		LastName  string        `json:"last_name"`
+		Email     string        `json:"email"`
+		Phone     string        `json:"phone"`
+	}
+
+	// PatchRequest es un PATCH /users/{id} en formato JSON Patch o JSON Merge Patch
+	PatchRequest struct {
+		ID         string
+		IfMatch    *Precondition
+		Operations []PatchOperation
+	}
+
+	// UpdateBatchRequest selecciona los usuarios por IDs o filtros (query string) y lleva en el body los campos a cambiar
+	UpdateBatchRequest struct {
+		Filters   Filters `json:"-"`
+		DryRun    bool    `json:"-"`
+		FirstName *string `json:"first_name"`
+		LastName  *string `json:"last_name"`
+		Email     *string `json:"email"`
+		Phone     *string `json:"phone"`
+	}
+
+	// DeleteBatchRequest selecciona los usuarios a borrar por IDs o filtros (query string)
+	DeleteBatchRequest struct {
+		Filters Filters
+		DryRun  bool
+	}
+
+	Response struct {
+		Status int         `json:"status"`
+		Data   interface{} `json:"data,omitempty"`
+		Err    string      `json:"error,omitempty"`
+		Meta   *meta.Meta  `json:"meta,omitempty"`
+	}
+
+	Config struct {
+		LimPageDef string
+		// RequireIfMatch exige el header If-Match en PATCH, PUT y DELETE de /users/{id}
+		RequireIfMatch bool
+		// IdempotencyTTL es cuánto se recuerda una Idempotency-Key (0 usa DefaultIdempotencyTTL)
+		IdempotencyTTL time.Duration
+		// IdempotencyMaxKeys es cuántas Idempotency-Key se recuerdan como máximo (0 usa DefaultIdempotencyMaxKeys)
+		IdempotencyMaxKeys int
+	}
+)
+
+func MakeEndpoints(s Service, config Config) Endpoint {
+	return Endpoint{
+		Create:      idempotent(newIdempotencyStore(config.IdempotencyTTL, config.IdempotencyMaxKeys), makeCreateEndpoint(s)),
+		CreateBatch: makeCreateBatchEndpoint(s),
+		Get:         makeGetEndpoint(s),
+		GetByEmail:  makeGetByEmailEndpoint(s),
+		GetAll:      makeGetAllEndpoint(s, config),
+		Update:      makeUpdateEndpoint(s, config),
+		UpdateBatch: makeUpdateBatchEndpoint(s),
+		Replace:     makeReplaceEndpoint(s, config),
+		Patch:       makePatchEndpoint(s, config),
+		Delete:      makeDeleteEndpoint(s, config),
+		DeleteBatch: makeDeleteBatchEndpoint(s),
+		Restore:     makeRestoreEndpoint(s),
+		Errors:      makeErrorsEndpoint(),
+	}
+}
+
+func makeCreateEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(CreateRequest)
+
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		user, err := s.Create(ctx, req.FirstName, req.LastName, req.Email, req.Phone)
+		if err != nil {
+			return nil, errorResponse(err, "error creating user")
+		}
+
+		return response.Created("User created successfully", user, nil), nil
+	}
+}
+
+func makeCreateBatchEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(CreateBatchRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		users := make([]domain.User, len(req.Items))
+		for i, item := range req.Items {
+			users[i] = domain.User{
+				FirstName: item.FirstName,
+				LastName:  item.LastName,
+				Email:     item.Email,
+				Phone:     item.Phone,
+			}
+		}
+
+		results, err := s.CreateBatch(ctx, users, req.Atomic)
+		if err != nil {
+			return nil, errorResponse(err, "error creating users")
+		}
+
+		items := make([]BatchItem, len(results))
+		summary := &BatchSummary{Total: len(results)}
+		// status del primer usuario que hizo fallar un lote atomic
+		failedStatus := 0
+		for i, result := range results {
+			items[i] = BatchItem{Index: result.Index}
+			if result.Err == nil {
+				items[i].Status = http.StatusCreated
+				items[i].Data = result.User
+				summary.Succeeded++
+				continue
+			}
+
+			summary.Failed++
+			errResp := errorResponse(result.Err, "error creating user")
+			items[i].Status = errResp.StatusCode()
+			items[i].Error = errResp
+			if failedStatus == 0 && !errors.Is(result.Err, ErrBatchAborted) {
+				failedStatus = errResp.StatusCode()
+			}
+		}
+
+		switch {
+		case summary.Failed == 0:
+			return Batch(http.StatusCreated, "Users created successfully", items, summary), nil
+		case req.Atomic:
+			// 💥 Ningún usuario tiene un error propio (ej: falló el commit): es un error del servidor
+			if failedStatus == 0 {
+				failedStatus = http.StatusInternalServerError
+			}
+			return Batch(failedStatus, ErrBatchAborted.Error(), items, summary), nil
+		default:
+			return Batch(http.StatusMultiStatus, "Users created with errors", items, summary), nil
+		}
+	}
+}
+
+func makeGetEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(GetRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		user, err := s.Get(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err, "error retrieving user")
+		}
+
+		// 🎯 GET condicional: si el cliente ya tiene esta versión respondemos 304 sin body
+		if req.Cache.NotModified(VersionOf(*user).ETag(LanguageFromContext(ctx)), user.UpdatedAt) {
+			return NotModified(versionHeaders(ctx, user)), nil
+		}
+
+		return withVersion(ctx, response.OK("User retrieved successfully", user, nil), user), nil
+	}
+}
+
+func makeGetByEmailEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(GetByEmailRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		if req.Email == "" {
+			return nil, errorResponse(ErrEmailRequired, "invalid request")
+		}
+
+		user, err := s.GetByEmail(ctx, req.Email)
+		if err != nil {
+			return nil, errorResponse(err, "error retrieving user")
+		}
+
+		return response.OK("User retrieved successfully", user, nil), nil
+	}
+}
+
+func makeGetAllEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+
+		v, ok := request.(GetAllRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		filters := Filters{
+			IDs:           v.IDs,
+			FirstName:     v.FirstName,
+			LastName:      v.LastName,
+			Email:         v.Email,
+			Phone:         v.Phone,
+			Query:         v.Query,
+			Conditions:    v.Conditions,
+			CreatedAfter:  v.CreatedAfter,
+			CreatedBefore: v.CreatedBefore,
+			UpdatedAfter:  v.UpdatedAfter,
+			UpdatedBefore: v.UpdatedBefore,
+			Sort:          v.Sort,
+			Deleted:       v.Deleted,
+		}
+
+		// Extraemos limit y page directamente del struct GetAllRequest
+		// Si los valores son 0 (no proporcionados), usaremos valores por defecto
+		limit := v.Limit
+		page := v.Page
+
+		// 🔧 Validación: si limit es 0, usamos el valor por defecto de la configuración
+		if limit <= 0 {
+			defaultLimit, err := strconv.Atoi(config.LimPageDef)
+			if err != nil {
+				return nil, errorResponse(ErrInvalidDefaultLimitConfiguration, "invalid configuration")
+			}
+			limit = defaultLimit
+		}
+
+		// El cursor depende del orden created_at desc: no se combina con sort ni con el ranking de q.
+		// 💡 Se valida antes del GET condicional: una request inválida nunca responde 304.
+		if v.CursorMode && (len(filters.Sort) > 0 || filters.Query != "") {
+			return nil, errorResponse(ErrCursorWithSort, "invalid request")
+		}
+
+		// 🎯 Validadores del listado: cantidad bajo los filtros y updated_at más reciente
+		stats, err := s.Stats(ctx, filters)
+		if err != nil {
+			return nil, errorResponse(err, "error counting users")
+		}
+		etag := CollectionETag(stats.Count, stats.LastModified, LanguageFromContext(ctx))
+		headers := cacheHeaders(etag, stats.LastModified)
+		if v.Cache.NotModified(etag, stats.LastModified) {
+			return NotModified(headers), nil
+		}
+
+		// 🎯 Modo cursor: paginación por keyset, sin OFFSET
+		if v.CursorMode {
+			resp, err := getAllByCursor(ctx, s, filters, v.Cursor, limit)
+			if err != nil {
+				return nil, err
+			}
+			return WithHeaders(resp, headers), nil
+		}
+
+		// 🔧 Validación: si page es 0 o negativo, establecemos página 1
+		if page <= 0 {
+			page = 1
+		}
+
+		metaData, err := meta.New(page, limit, int(stats.Count), config.LimPageDef)
+		if err != nil {
+			return nil, errorResponse(err, "error generating metadata")
+		}
+
+		users, err := s.GetAll(ctx, filters, metaData.Offset(), metaData.Limit())
+		if err != nil {
+			return nil, errorResponse(err, "error retrieving users")
+		}
+
+		return WithHeaders(response.OK("Users retrieved successfully", users, metaData), headers), nil
+	}
+}
+
+// getAllByCursor pide un usuario extra para saber si hay otra página y, si la hay, arma next_cursor
+func getAllByCursor(ctx context.Context, s Service, filters Filters, after *Cursor, limit int) (response.Response, error) {
+	users, err := s.GetAllByCursor(ctx, filters, after, limit+1)
+	if err != nil {
+		return nil, errorResponse(err, "error retrieving users")
+	}
+
+	pageMeta := &CursorMeta{PerPage: limit}
+	if len(users) > limit {
+		users = users[:limit]
+		pageMeta.NextCursor = NewCursor(users[limit-1]).Encode()
+	}
+
+	return CursorPage("Users retrieved successfully", users, pageMeta), nil
+}
+
+func makeUpdateEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(UpdateRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		// 🎯 Validación: el ID es requerido
+		if req.ID == "" {
+			return nil, errorResponse(ErrIDRequired, "invalid request")
+		}
+
+		// 🎯 Validación: al menos un campo debe ser proporcionado para actualizar
+		if req.FirstName == nil && req.LastName == nil && req.Email == nil && req.Phone == nil {
+			return nil, errorResponse(ErrAtLeastOneFieldRequired, "invalid request")
+		}
+
+		if config.RequireIfMatch && req.IfMatch == nil {
+			return nil, errorResponse(ErrIfMatchRequired, "invalid request")
+		}
+
+		// ✅ Llamamos al servicio y obtenemos el usuario actualizado
+		user, err := s.Update(ctx, req.ID, req.IfMatch, req.FirstName, req.LastName, req.Email, req.Phone)
+		if err != nil {
+			return nil, errorResponse(err, "error updating user")
+		}
+
+		// ✅ Retornamos el usuario actualizado (y su nueva versión) en la respuesta
+		return withVersion(ctx, response.OK("User updated successfully", user, nil), user), nil
+	}
+}
+
+func makeReplaceEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(ReplaceRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		if req.ID == "" {
+			return nil, errorResponse(ErrIDRequired, "invalid request")
+		}
+
+		if config.RequireIfMatch && req.IfMatch == nil {
+			return nil, errorResponse(ErrIfMatchRequired, "invalid request")
+		}
+
+		user, created, err := s.Replace(ctx, req.ID, req.IfMatch, req.FirstName, req.LastName, req.Email, req.Phone)
+		if err != nil {
+			return nil, errorResponse(err, "error replacing user")
+		}
+
+		if created {
+			return withVersion(ctx, response.Created("User created successfully", user, nil), user), nil
+		}
+		return withVersion(ctx, response.OK("User replaced successfully", user, nil), user), nil
+	}
+}
+
+func makePatchEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(PatchRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		if req.ID == "" {
+			return nil, errorResponse(ErrIDRequired, "invalid request")
+		}
+
+		if config.RequireIfMatch && req.IfMatch == nil {
+			return nil, errorResponse(ErrIfMatchRequired, "invalid request")
+		}
+
+		user, err := s.Patch(ctx, req.ID, req.IfMatch, req.Operations)
+		if err != nil {
+			return nil, errorResponse(err, "error updating user")
+		}
+
+		return withVersion(ctx, response.OK("User updated successfully", user, nil), user), nil
+	}
+}
+
+func makeUpdateBatchEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(UpdateBatchRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		// 🎯 El email es único: no tiene sentido asignar el mismo a varios usuarios
+		if req.Email != nil {
+			return nil, errorResponse(ErrBulkEmailUpdate, "invalid request")
+		}
+		if req.FirstName == nil && req.LastName == nil && req.Phone == nil {
+			return nil, errorResponse(ErrAtLeastOneFieldRequired, "invalid request")
+		}
+
+		result, err := s.UpdateMany(ctx, req.Filters, req.FirstName, req.LastName, req.Phone, req.DryRun)
+		if err != nil {
+			return nil, errorResponse(err, "error updating users")
+		}
+
+		if req.DryRun {
+			return response.OK("Dry run: no users were updated", result, nil), nil
+		}
+		return response.OK("Users updated successfully", result, nil), nil
+	}
+}
+
+func makeDeleteEndpoint(s Service, config Config) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DeleteRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		// 🎯 Validación: el ID es requerido
+		if req.ID == "" {
+			return nil, errorResponse(ErrIDRequired, "invalid request")
+		}
+
+		if config.RequireIfMatch && req.IfMatch == nil {
+			return nil, errorResponse(ErrIfMatchRequired, "invalid request")
+		}
+
+		// 🗑️ Borrado definitivo: el usuario no se puede restaurar
+		if req.Hard {
+			if err := s.Purge(ctx, req.ID, req.IfMatch); err != nil {
+				return nil, errorResponse(err, "error deleting user")
+			}
+			return response.OK("User permanently deleted", nil, nil), nil
+		}
+
+		// 💡 Llamamos al servicio para eliminar el usuario
+		err := s.Delete(ctx, req.ID, req.IfMatch)
+		if err != nil {
+			return nil, errorResponse(err, "error deleting user")
+		}
+
+		// ✅ Retornamos un mensaje de éxito
+		return response.OK("User deleted successfully", nil, nil), nil
+	}
+}
+
+func makeRestoreEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(RestoreRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		if req.ID == "" {
+			return nil, errorResponse(ErrIDRequired, "invalid request")
+		}
+
+		user, err := s.Restore(ctx, req.ID)
+		if err != nil {
+			return nil, errorResponse(err, "error restoring user")
+		}
+
+		return withVersion(ctx, response.OK("User restored successfully", user, nil), user), nil
+	}
+}
+
+func makeDeleteBatchEndpoint(s Service) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DeleteBatchRequest)
+		if !ok {
+			return nil, errorResponse(ErrInvalidRequestType, "invalid request")
+		}
+
+		result, err := s.DeleteMany(ctx, req.Filters, req.DryRun)
+		if err != nil {
+			return nil, errorResponse(err, "error deleting users")
+		}
+
+		if req.DryRun {
+			return response.OK("Dry run: no users were deleted", result, nil), nil
+		}
+		return response.OK("Users deleted successfully", result, nil), nil
+	}
+}
+
+// withVersion agrega a la respuesta los validadores (ETag y Last-Modified) del usuario
+func withVersion(ctx context.Context, resp response.Response, user *domain.User) response.Response {
+	return WithHeaders(resp, versionHeaders(ctx, user))
+}
+
+// versionHeaders arma los validadores del usuario; el ETag es el del idioma de la respuesta
+func versionHeaders(ctx context.Context, user *domain.User) http.Header {
+	return cacheHeaders(VersionOf(*user).ETag(LanguageFromContext(ctx)), user.UpdatedAt)
+}
+
+// cacheHeaders arma los headers ETag y Last-Modified (este último solo si hay fecha)
+func cacheHeaders(etag string, lastModified time.Time) http.Header {
+	header := http.Header{"ETag": {etag}}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	return header
+}
+
+// makeErrorsEndpoint devuelve el catálogo de errores de la API
+func makeErrorsEndpoint() Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return response.OK("Error catalog retrieved successfully", ErrorCatalog(LanguageFromContext(ctx)), nil), nil
+	}
+}
+
+// errorResponse traduce los errores del servicio a un problema (RFC 9457) con el status correspondiente.
+// fallback es el detalle de los errores no contemplados (BD, conexión, etc.)
+func errorResponse(err error, fallback string) response.Response {
+	if problem, ok := NewProblem(err); ok {
+		return problem
+	}
+
+	// 💥 Para otros errores (BD, conexión, etc.) nunca exponemos err: puede tener detalles internos
+	return InternalProblem(fallback)
+}
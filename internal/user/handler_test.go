@@ -0,0 +1,147 @@
+package user_test
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+)
+
+func newTestEndpoints() user.Endpoint {
+	logger := log.New(io.Discard, "", 0)
+	repo := user.NewMemoryRepository(logger)
+	service := user.NewService(logger, repo, user.ServiceConfig{DefaultCountry: "AR"})
+	return user.MakeEndpoints(service, user.Config{LimPageDef: "10"})
+}
+
+func TestCreateEndpointDuplicateEmail(t *testing.T) {
+	ctx := context.Background()
+	endpoints := newTestEndpoints()
+
+	req := user.CreateRequest{FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234"}
+	if _, err := endpoints.Create(ctx, req); err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+
+	req.Email = "ANA@example.com"
+	_, err := endpoints.Create(ctx, req)
+	resp, ok := err.(response.Response)
+	if !ok {
+		t.Fatalf("expected response.Response error, got %T: %v", err, err)
+	}
+	if resp.StatusCode() != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", resp.StatusCode(), http.StatusConflict)
+	}
+	errResp, ok := resp.(*user.Problem)
+	if !ok || errResp.Field != "email" {
+		t.Fatalf("expected conflict on field email, got %#v", resp)
+	}
+}
+
+func TestCreateEndpointValidation(t *testing.T) {
+	endpoints := newTestEndpoints()
+
+	req := user.CreateRequest{FirstName: "", LastName: "Gomez", Email: "ana.example.com", Phone: "4555"}
+	_, err := endpoints.Create(context.Background(), req)
+
+	errResp, ok := err.(*user.Problem)
+	if !ok {
+		t.Fatalf("expected *user.Problem, got %T: %v", err, err)
+	}
+	if errResp.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", errResp.StatusCode(), http.StatusBadRequest)
+	}
+	for _, field := range []string{"first_name", "email", "phone"} {
+		if len(errResp.Errors[field]) == 0 {
+			t.Errorf("expected an error for %s, got %v", field, errResp.Errors)
+		}
+	}
+	if _, ok := errResp.Errors["last_name"]; ok {
+		t.Errorf("unexpected error for last_name: %v", errResp.Errors)
+	}
+}
+
+func TestRequireIfMatch(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	service := user.NewService(logger, user.NewMemoryRepository(logger), user.ServiceConfig{DefaultCountry: "AR"})
+	endpoints := user.MakeEndpoints(service, user.Config{LimPageDef: "10", RequireIfMatch: true})
+
+	created, err := service.Create(ctx, "Ana", "Gomez", "ana@example.com", "+541145551234")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	name := "Eva"
+	_, err = endpoints.Update(ctx, user.UpdateRequest{ID: created.ID, FirstName: &name})
+	assertStatus(t, err, http.StatusPreconditionRequired)
+	_, err = endpoints.Delete(ctx, user.DeleteRequest{ID: created.ID})
+	assertStatus(t, err, http.StatusPreconditionRequired)
+
+	ifMatch := &user.Precondition{Version: user.VersionOf(*created)}
+	if _, err := endpoints.Update(ctx, user.UpdateRequest{ID: created.ID, IfMatch: ifMatch, FirstName: &name}); err != nil {
+		t.Fatalf("Update with If-Match: %v", err)
+	}
+	// La versión cambió con el update anterior
+	_, err = endpoints.Delete(ctx, user.DeleteRequest{ID: created.ID, IfMatch: ifMatch})
+	assertStatus(t, err, http.StatusPreconditionFailed)
+}
+
+func assertStatus(t *testing.T, err error, status int) {
+	t.Helper()
+
+	resp, ok := err.(response.Response)
+	if !ok {
+		t.Fatalf("expected response.Response error, got %T: %v", err, err)
+	}
+	if resp.StatusCode() != status {
+		t.Fatalf("status = %d, want %d (%v)", resp.StatusCode(), status, resp)
+	}
+}
+
+// failingBatchRepository simula un lote atomic cuya transacción falla (ej: en el commit)
+type failingBatchRepository struct {
+	user.Repository
+	err error
+}
+
+func (r failingBatchRepository) CreateBatch(_ context.Context, users []*domain.User, _ bool) []error {
+	errs := make([]error, len(users))
+	for i := range errs {
+		errs[i] = r.err
+	}
+	return errs
+}
+
+// 🎯 Un lote atomic que falla sin un usuario culpable responde 500, nunca un status 0
+func TestCreateBatchEndpointTransactionFailure(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	items := []user.CreateRequest{
+		{FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234"},
+		{FirstName: "Bruno", LastName: "Diaz", Email: "bruno@example.com", Phone: "+541145559876"},
+	}
+
+	// El repositorio GORM devuelve ErrUserNotCreated; ErrBatchAborted en todos cubre cualquier otra implementación
+	for _, repoErr := range []error{user.ErrUserNotCreated, user.ErrBatchAborted} {
+		repo := failingBatchRepository{Repository: user.NewMemoryRepository(logger), err: repoErr}
+		service := user.NewService(logger, repo, user.ServiceConfig{DefaultCountry: "AR"})
+		endpoints := user.MakeEndpoints(service, user.Config{LimPageDef: "10"})
+
+		resp, err := endpoints.CreateBatch(context.Background(), user.CreateBatchRequest{Items: items, Atomic: true})
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", repoErr, err)
+		}
+		batch, ok := resp.(*user.BatchResponse)
+		if !ok {
+			t.Fatalf("%v: expected *user.BatchResponse, got %T", repoErr, resp)
+		}
+		if batch.StatusCode() != http.StatusInternalServerError || batch.Meta.Failed != len(items) {
+			t.Fatalf("%v: status = %d, summary %+v", repoErr, batch.StatusCode(), batch.Meta)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package user
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+const (
+	// DefaultIdempotencyTTL es cuánto se recuerda una Idempotency-Key si Config no indica otro valor
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyMaxKeys es cuántas Idempotency-Key se recuerdan como máximo si Config no indica otro valor
+	DefaultIdempotencyMaxKeys = 10000
+	// MaxIdempotencyKeyLength es el largo máximo aceptado para el header Idempotency-Key
+	MaxIdempotencyKeyLength = 255
+
+	idempotencySweepInterval = time.Minute
+)
+
+// idempotencyStore recuerda, por Idempotency-Key, el hash del request y la respuesta que se dio.
+// Vive en memoria: cada instancia del servicio tiene el suyo y se pierde al reiniciar (ver doc.go).
+// 💡 Recuerda como máximo maxKeys keys: al llenarse descarta la respuesta usada hace más tiempo, así
+// keys únicas no hacen crecer la memoria sin límite. Las keys en curso nunca se descartan (un reintento
+// ejecutaría el alta otra vez): si todas están en curso, la key nueva se rechaza con ErrIdempotencyKeysBusy.
+type idempotencyStore struct {
+	ttl     time.Duration
+	maxKeys int
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order tiene las entradas de la usada hace más tiempo a la más reciente
+	order *list.List
+	swept time.Time
+}
+
+// idempotencyEntry es el resultado de una key. done se cierra cuando termina el primer request;
+// hasta entonces los duplicados esperan.
+type idempotencyEntry struct {
+	key     string
+	hash    string
+	done    chan struct{}
+	stored  bool
+	resp    interface{}
+	err     error
+	expires time.Time
+}
+
+func newIdempotencyStore(ttl time.Duration, maxKeys int) *idempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	if maxKeys <= 0 {
+		maxKeys = DefaultIdempotencyMaxKeys
+	}
+	return &idempotencyStore{ttl: ttl, maxKeys: maxKeys, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// begin devuelve la entrada de key. Si owner es true el llamador es el primero con esa key:
+// debe ejecutar el request y llamar a finish. Si no, la entrada ya tiene la respuesta a repetir.
+func (s *idempotencyStore) begin(ctx context.Context, key, hash string) (*idempotencyEntry, bool, error) {
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		s.sweep(now)
+		elem, ok := s.entries[key]
+		if !ok || elem.Value.(*idempotencyEntry).expired(now) {
+			entry, err := s.add(key, hash)
+			s.mu.Unlock()
+			return entry, err == nil, err
+		}
+		s.order.MoveToBack(elem)
+		entry := elem.Value.(*idempotencyEntry)
+		s.mu.Unlock()
+
+		if entry.hash != hash {
+			return nil, false, ErrIdempotencyKeyReused
+		}
+
+		// ⏳ Duplicado en curso: esperamos a que termine el primero
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if entry.stored {
+			return entry, false, nil
+		}
+		// El primero no dejó una respuesta para repetir (error interno): lo intentamos nosotros
+	}
+}
+
+// finish guarda la respuesta del primer request y libera a los duplicados que esperan.
+// Los errores internos no se guardan: un reintento con la misma key vuelve a ejecutarse.
+func (s *idempotencyStore) finish(key string, entry *idempotencyEntry, resp interface{}, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if replayable(err) {
+		entry.resp, entry.err, entry.stored = resp, err, true
+		entry.expires = time.Now().Add(s.ttl)
+	} else if elem, ok := s.entries[key]; ok && elem.Value == entry {
+		s.remove(elem)
+	}
+	close(entry.done)
+}
+
+// add registra una entrada nueva para key (reemplaza a la vencida, si había una). Para hacerle lugar
+// descarta las respuestas guardadas usadas hace más tiempo; si no alcanza devuelve ErrIdempotencyKeysBusy.
+// Requiere tener tomado s.mu.
+func (s *idempotencyStore) add(key, hash string) (*idempotencyEntry, error) {
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	for elem := s.order.Front(); elem != nil && s.order.Len() >= s.maxKeys; {
+		next := elem.Next()
+		if elem.Value.(*idempotencyEntry).stored {
+			s.remove(elem)
+		}
+		elem = next
+	}
+	if s.order.Len() >= s.maxKeys {
+		return nil, ErrIdempotencyKeysBusy
+	}
+
+	entry := &idempotencyEntry{key: key, hash: hash, done: make(chan struct{})}
+	s.entries[key] = s.order.PushBack(entry)
+	return entry, nil
+}
+
+// remove olvida la entrada de elem; requiere tener tomado s.mu
+func (s *idempotencyStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*idempotencyEntry).key)
+}
+
+// expired indica si la respuesta guardada ya no se repite
+func (e *idempotencyEntry) expired(now time.Time) bool {
+	return e.stored && now.After(e.expires)
+}
+
+// sweep descarta las keys vencidas; requiere tener tomado s.mu
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < idempotencySweepInterval {
+		return
+	}
+	s.swept = now
+	for _, elem := range s.entries {
+		if elem.Value.(*idempotencyEntry).expired(now) {
+			s.remove(elem)
+		}
+	}
+}
+
+// replayable indica si el resultado se puede repetir: todo salvo los errores 5xx
+func replayable(err error) bool {
+	var resp response.Response
+	if err == nil {
+		return true
+	}
+	return errors.As(err, &resp) && resp.StatusCode() < http.StatusInternalServerError
+}
+
+// errRequestInterrupted es el resultado de un request que no terminó (next entró en pánico)
+var errRequestInterrupted = errors.New("request interrupted")
+
+// idempotent hace que next responda lo mismo a los reintentos de un CreateRequest con IdempotencyKey
+func idempotent(store *idempotencyStore, next Controller) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(CreateRequest)
+		if !ok || req.IdempotencyKey == "" {
+			return next(ctx, request)
+		}
+
+		// El hash se calcula sobre el request decodificado: espacios u orden de las claves no cuentan
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, errorResponse(err, "error hashing request")
+		}
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+
+		entry, owner, err := store.begin(ctx, req.IdempotencyKey, hash)
+		if err != nil {
+			return nil, errorResponse(err, "error checking idempotency key")
+		}
+		if !owner {
+			// replayable garantiza que el error guardado es una response.Response
+			var errResp response.Response
+			if errors.As(entry.err, &errResp) {
+				return nil, replayed(errResp)
+			}
+			return replayed(entry.resp.(response.Response)), nil
+		}
+
+		// 💥 finish va en un defer: si next entra en pánico la key se libera sin guardar nada
+		// (errRequestInterrupted no se repite) y los duplicados que esperan lo reintentan
+		var resp interface{}
+		err = errRequestInterrupted
+		defer func() {
+			store.finish(req.IdempotencyKey, entry, resp, err)
+		}()
+		resp, err = next(ctx, request)
+		return resp, err
+	}
+}
+
+// replayed marca la respuesta repetida con el header Idempotent-Replayed
+func replayed(resp response.Response) response.Response {
+	header := http.Header{}
+	if withHeaders, ok := resp.(*HeaderResponse); ok {
+		header = withHeaders.Headers().Clone()
+		resp = withHeaders.Response
+	}
+	header.Set("Idempotent-Replayed", "true")
+	return WithHeaders(resp, header)
+}
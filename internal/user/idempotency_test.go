@@ -0,0 +1,285 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+// countingController cuenta las llamadas y responde con resp/err
+func countingController(calls *int32, resp interface{}, err error) Controller {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		atomic.AddInt32(calls, 1)
+		return resp, err
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	created := response.Created("User created successfully", map[string]string{"id": "1"}, nil)
+	create := idempotent(newIdempotencyStore(time.Hour, 0), countingController(&calls, created, nil))
+
+	req := CreateRequest{FirstName: "Ana", Email: "ana@example.com", IdempotencyKey: "key-1"}
+	first, err := create(ctx, req)
+	if err != nil || first != created {
+		t.Fatalf("first call = %v, %v", first, err)
+	}
+
+	second, err := create(ctx, req)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	replay, ok := second.(*HeaderResponse)
+	if !ok || replay.Response != created || replay.Headers().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay = %#v, want the original response marked as replayed", second)
+	}
+	if calls != 1 {
+		t.Fatalf("next called %d times, want 1", calls)
+	}
+
+	// Sin key no hay idempotencia
+	if _, err := create(ctx, CreateRequest{FirstName: "Ana"}); err != nil {
+		t.Fatalf("without key: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("next called %d times without key, want 2", calls)
+	}
+}
+
+func TestIdempotentKeyReused(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	create := idempotent(newIdempotencyStore(time.Hour, 0), countingController(&calls, response.Created("ok", nil, nil), nil))
+
+	if _, err := create(ctx, CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"}); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	_, err := create(ctx, CreateRequest{Email: "eva@example.com", IdempotencyKey: "key-1"})
+	var resp response.Response
+	if !errors.As(err, &resp) || resp.StatusCode() != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with another payload: expected 422, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("next called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotentErrors(t *testing.T) {
+	ctx := context.Background()
+	req := CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"}
+
+	// Un 4xx se repite tal cual
+	var calls int32
+	conflict := errorResponse(NewErrAlreadyExists("email", "ana@example.com"), "error creating user")
+	create := idempotent(newIdempotencyStore(time.Hour, 0), countingController(&calls, nil, conflict))
+	for i := 0; i < 2; i++ {
+		if _, err := create(ctx, req); err == nil || err.Error() != conflict.Error() {
+			t.Fatalf("call %d: expected the conflict, got %v", i, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("4xx: next called %d times, want 1", calls)
+	}
+
+	// Un error interno no se guarda: el reintento vuelve a ejecutarse
+	calls = 0
+	create = idempotent(newIdempotencyStore(time.Hour, 0), countingController(&calls, nil, response.InternalServerError("db down")))
+	for i := 0; i < 2; i++ {
+		if _, err := create(ctx, req); err == nil {
+			t.Fatalf("call %d: expected an error", i)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("5xx: next called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotentExpiry(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	create := idempotent(newIdempotencyStore(time.Millisecond, 0), countingController(&calls, response.Created("ok", nil, nil), nil))
+	req := CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"}
+
+	if _, err := create(ctx, req); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := create(ctx, CreateRequest{Email: "eva@example.com", IdempotencyKey: "key-1"}); err != nil {
+		t.Fatalf("expired key with another payload: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("next called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotentEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	create := idempotent(newIdempotencyStore(time.Hour, 2), countingController(&calls, response.Created("ok", nil, nil), nil))
+	call := func(key string) {
+		t.Helper()
+		if _, err := create(ctx, CreateRequest{Email: "ana@example.com", IdempotencyKey: key}); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+	}
+
+	call("key-1")
+	call("key-2")
+	call("key-1") // repetición: key-1 pasa a ser la usada más recientemente
+	call("key-3") // se llena el store y se descarta key-2
+	if calls != 3 {
+		t.Fatalf("next called %d times, want 3", calls)
+	}
+
+	call("key-1")
+	if calls != 3 {
+		t.Fatalf("key-1 was evicted: next called %d times, want 3", calls)
+	}
+	call("key-2")
+	if calls != 4 {
+		t.Fatalf("key-2 was kept: next called %d times, want 4", calls)
+	}
+}
+
+func TestIdempotentKeepsInFlightKeys(t *testing.T) {
+	ctx := context.Background()
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls int32
+	create := idempotent(newIdempotencyStore(time.Hour, 1), func(ctx context.Context, request interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+			<-release
+		}
+		return response.Created("ok", nil, nil), nil
+	})
+
+	first := make(chan error)
+	go func() {
+		_, err := create(ctx, CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"})
+		first <- err
+	}()
+	<-entered
+
+	// 🎯 key-1 sigue en curso: no se descarta para hacerle lugar a key-2
+	_, err := create(ctx, CreateRequest{Email: "eva@example.com", IdempotencyKey: "key-2"})
+	if problem, ok := err.(*Problem); !ok || problem.StatusCode() != http.StatusServiceUnavailable {
+		t.Fatalf("new key with every slot in flight: expected 503, got %v", err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("key-1: %v", err)
+	}
+	// Con key-1 ya respondida sí se la puede descartar
+	if _, err := create(ctx, CreateRequest{Email: "eva@example.com", IdempotencyKey: "key-2"}); err != nil {
+		t.Fatalf("key-2 after key-1 finished: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("next called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotentPanicReleasesKey(t *testing.T) {
+	ctx := context.Background()
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls int32
+	create := idempotent(newIdempotencyStore(time.Hour, 0), func(ctx context.Context, request interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+			<-release
+			panic("boom")
+		}
+		return response.Created("ok", nil, nil), nil
+	})
+	req := CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"}
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		_, _ = create(ctx, req)
+	}()
+	<-entered
+
+	// 💥 El duplicado que espera no queda bloqueado: al fallar el primero lo ejecuta él
+	duplicate := make(chan error)
+	go func() {
+		timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		_, err := create(timeout, req)
+		duplicate <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if r := <-panicked; r == nil {
+		t.Fatal("expected the panic to propagate")
+	}
+	if err := <-duplicate; err != nil {
+		t.Fatalf("duplicate after panic: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("next called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotentConcurrentDuplicates(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+	release := make(chan struct{})
+	created := response.Created("ok", nil, nil)
+	create := idempotent(newIdempotencyStore(time.Hour, 0), func(ctx context.Context, request interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return created, nil
+	})
+	req := CreateRequest{Email: "ana@example.com", IdempotencyKey: "key-1"}
+
+	const n = 10
+	var wg sync.WaitGroup
+	results := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = create(ctx, req)
+		}(i)
+	}
+
+	// 🎯 Mientras el primero no termina, los demás esperan sin llamar a next
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("next called %d times, want 1", calls)
+	}
+	for i, resp := range results {
+		if resp == nil || resp.(response.Response).StatusCode() != http.StatusCreated {
+			t.Fatalf("result %d = %v, want the created response", i, resp)
+		}
+	}
+
+	// Un duplicado que se cansa de esperar recibe el error del contexto
+	waiting := make(chan struct{})
+	store := newIdempotencyStore(time.Hour, 0)
+	slow := idempotent(store, func(ctx context.Context, request interface{}) (interface{}, error) {
+		close(waiting)
+		<-release
+		time.Sleep(50 * time.Millisecond)
+		return created, nil
+	})
+	go slow(ctx, req)
+	<-waiting
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := slow(timeout, req); err == nil {
+		t.Fatal("duplicate with canceled context: expected an error")
+	}
+}
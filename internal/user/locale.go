@@ -0,0 +1,210 @@
+package user
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+// DefaultLanguage es el idioma de los mensajes cuando el cliente no pide uno que tengamos
+const DefaultLanguage = "en"
+
+// 🌐 Catálogos de mensajes: un archivo por idioma (ej: locales/es.json -> es), cada uno un objeto
+// JSON con el texto en inglés como clave. Agregar un idioma es solo agregar su archivo.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs tiene los mensajes de cada idioma, indexados por su texto en inglés
+var catalogs = loadCatalogs(localeFiles)
+
+func loadCatalogs(files fs.FS) map[string]map[string]string {
+	paths, err := fs.Glob(files, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]string, len(paths))
+	for _, p := range paths {
+		data, err := fs.ReadFile(files, p)
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("locale %s: %v", p, err))
+		}
+		loaded[strings.ToLower(strings.TrimSuffix(path.Base(p), ".json"))] = catalog
+	}
+	return loaded
+}
+
+// Languages devuelve los idiomas que tienen catálogo
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// MatchLanguage elige, según el header Accept-Language, el idioma con catálogo que el cliente prefiere.
+// Acepta variantes regionales (es-AR usa es); si ninguno coincide devuelve DefaultLanguage.
+func MatchLanguage(acceptLanguage string) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, q := parseLanguageRange(part)
+		// A igual peso gana el primero, como lo envió el cliente
+		if q <= bestQ {
+			continue
+		}
+		if lang, ok := supportedLanguage(tag); ok {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// parseLanguageRange interpreta un elemento de Accept-Language, ej: "es-AR;q=0.8"
+func parseLanguageRange(part string) (string, float64) {
+	tag, params, _ := strings.Cut(part, ";")
+	q := 1.0
+	if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", 0
+		}
+		q = parsed
+	}
+	return strings.ToLower(strings.TrimSpace(tag)), q
+}
+
+func supportedLanguage(tag string) (string, bool) {
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := catalogs[base]; ok {
+		return base, true
+	}
+	return "", false
+}
+
+type languageKey struct{}
+
+// WithLanguage guarda en ctx el idioma de las respuestas del request
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// LanguageFromContext devuelve el idioma guardado con WithLanguage, o DefaultLanguage
+func LanguageFromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// translate devuelve el texto de key en lang; si falta usa el inglés y, por último, la propia key
+func translate(lang, key string) string {
+	if text := catalogs[lang][key]; text != "" {
+		return text
+	}
+	if text := catalogs[DefaultLanguage][key]; text != "" {
+		return text
+	}
+	return key
+}
+
+// message es un texto para el cliente: format, en inglés, es la clave en los catálogos.
+// Los args que también son message se traducen al localizarlo.
+type message struct {
+	format string
+	args   []interface{}
+}
+
+func newMessage(format string, args ...interface{}) message {
+	return message{format: format, args: args}
+}
+
+// String devuelve el mensaje en inglés
+func (m message) String() string {
+	if len(m.args) == 0 {
+		return m.format
+	}
+	return fmt.Sprintf(m.format, m.args...)
+}
+
+// localize devuelve el mensaje en lang
+func (m message) localize(lang string) string {
+	format := translate(lang, m.format)
+	if len(m.args) == 0 {
+		return format
+	}
+
+	args := make([]interface{}, len(m.args))
+	for i, arg := range m.args {
+		if nested, ok := arg.(message); ok {
+			arg = nested.localize(lang)
+		}
+		args[i] = arg
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// messager lo implementan los errores con parámetros (ej: ErrNotFound) para poder traducirlos
+type messager interface {
+	message() message
+}
+
+// localizeError devuelve el texto de err en lang
+func localizeError(lang string, err error) string {
+	var m messager
+	if errors.As(err, &m) {
+		return m.message().localize(lang)
+	}
+	return translate(lang, err.Error())
+}
+
+// LocalizeResponse devuelve resp con sus mensajes en lang.
+// 💡 Nunca modifica resp: puede estar guardada para repetirse (Idempotency-Key) en otro idioma.
+func LocalizeResponse(resp response.Response, lang string) response.Response {
+	switch r := resp.(type) {
+	case *HeaderResponse:
+		return WithHeaders(LocalizeResponse(r.Response, lang), r.header)
+	case *Problem:
+		return r.Localize(lang)
+	case *response.SuccessResponse:
+		localized := *r
+		localized.Message = translate(lang, r.Message)
+		return &localized
+	case *CursorResponse:
+		localized := *r
+		localized.Message = translate(lang, r.Message)
+		return &localized
+	case *BatchResponse:
+		localized := *r
+		localized.Message = translate(lang, r.Message)
+		if r.Data != nil {
+			localized.Data = make([]BatchItem, len(r.Data))
+			for i, item := range r.Data {
+				if item.Error != nil {
+					item.Error = LocalizeResponse(item.Error, lang)
+				}
+				localized.Data[i] = item
+			}
+		}
+		return &localized
+	}
+	return resp
+}
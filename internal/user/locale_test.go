@@ -0,0 +1,232 @@
+package user
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+// messageArgs indica, por función, qué argumento es un mensaje para el cliente
+var messageArgs = map[string]int{
+	"newMessage":          0,
+	"add":                 1,
+	"NewErrInvalidFilter": 1,
+	"NewErrParameter":     1,
+	"NewErrPatchOp":       3,
+	"OK":                  0,
+	"Created":             0,
+	"CursorPage":          0,
+	"Batch":               1,
+	"errorResponse":       1,
+	"InternalProblem":     0,
+}
+
+var formatVerb = regexp.MustCompile(`%(\[\d+\])?[-+# 0-9.]*[a-zA-Z%]`)
+
+// sourceMessages junta los mensajes literales del código (paquete user y handler),
+// los textos de los errores de error.go y los títulos de los problemas
+func sourceMessages(t *testing.T) map[string]bool {
+	t.Helper()
+	messages := make(map[string]bool)
+	for _, sentinel := range sentinels {
+		messages[sentinel.Error()] = true
+	}
+	for _, pt := range append(problemTypes, internalProblem) {
+		messages[pt.title] = true
+	}
+
+	files, _ := filepath.Glob("*.go")
+	handlerFiles, _ := filepath.Glob(filepath.Join("..", "..", "pkg", "handler", "*.go"))
+	for _, name := range append(files, handlerFiles...) {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", name, err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			var fn string
+			switch f := call.Fun.(type) {
+			case *ast.Ident:
+				fn = f.Name
+			case *ast.SelectorExpr:
+				fn = f.Sel.Name
+			}
+			index, ok := messageArgs[fn]
+			if !ok || index >= len(call.Args) {
+				return true
+			}
+			if lit, ok := call.Args[index].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				text, _ := strconv.Unquote(lit.Value)
+				// Los formatos que solo unen otros mensajes (ej: "%s: %s") no se traducen
+				if strings.IndexFunc(formatVerb.ReplaceAllString(text, ""), unicode.IsLetter) >= 0 {
+					messages[text] = true
+				}
+			}
+			return true
+		})
+	}
+	return messages
+}
+
+func TestDefaultCatalogCoversMessages(t *testing.T) {
+	messages := sourceMessages(t)
+	english := catalogs[DefaultLanguage]
+	for text := range messages {
+		if _, ok := english[text]; !ok {
+			t.Errorf("locales/%s.json is missing %q", DefaultLanguage, text)
+		}
+	}
+	for text := range english {
+		if !messages[text] {
+			t.Errorf("locales/%s.json has %q, which the code no longer uses", DefaultLanguage, text)
+		}
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	if len(Languages()) < 2 {
+		t.Fatalf("languages = %v, want at least %s and one translation", Languages(), DefaultLanguage)
+	}
+	verbs := func(text string) string {
+		found := formatVerb.FindAllString(text, -1)
+		sort.Strings(found)
+		return strings.Join(found, " ")
+	}
+
+	english := catalogs[DefaultLanguage]
+	for _, lang := range Languages() {
+		catalog := catalogs[lang]
+		for key, text := range english {
+			translated, ok := catalog[key]
+			if !ok || translated == "" {
+				t.Errorf("locales/%s.json is missing %q", lang, key)
+				continue
+			}
+			if verbs(translated) != verbs(text) {
+				t.Errorf("locales/%s.json: %q must keep the verbs of %q", lang, translated, text)
+			}
+		}
+		for key := range catalog {
+			if _, ok := english[key]; !ok {
+				t.Errorf("locales/%s.json has %q, which is not in locales/%s.json", lang, key, DefaultLanguage)
+			}
+		}
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := map[string]string{
+		"":                          "en",
+		"es":                        "es",
+		"es-AR":                     "es",
+		"ES-ar,en;q=0.8":            "es",
+		"en-US,es;q=0.9":            "en",
+		"fr-FR,es;q=0.5":            "es",
+		"es;q=0.3,en;q=0.7":         "en",
+		"fr, de":                    "en",
+		"es;q=0":                    "en",
+		"es;q=abc,en":               "en",
+		"*":                         "en",
+		"pt-BR,pt;q=0.9,es-MX;q=.8": "es",
+	}
+	for header, want := range tests {
+		if got := MatchLanguage(header); got != want {
+			t.Errorf("MatchLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+
+	if lang := LanguageFromContext(context.Background()); lang != DefaultLanguage {
+		t.Errorf("LanguageFromContext without language = %q", lang)
+	}
+	if lang := LanguageFromContext(WithLanguage(context.Background(), "es")); lang != "es" {
+		t.Errorf("LanguageFromContext = %q, want es", lang)
+	}
+}
+
+func TestLocalizeProblem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "sentinel", err: ErrIDRequired, want: "el id es obligatorio"},
+		{name: "not found", err: NewErrNotFound("abc"), want: "no existe un usuario con ID abc"},
+		{name: "invalid filter", err: NewErrInvalidFilter("email[like]", "unknown operator"), want: "filtro inválido email[like]: operador desconocido"},
+		{name: "parameter", err: NewErrParameter("Idempotency-Key", "must be at most %d characters", 255), want: "parámetro inválido Idempotency-Key: debe tener como máximo 255 caracteres"},
+	}
+	for _, tt := range tests {
+		problem, _ := NewProblem(tt.err)
+		localized := problem.Localize("es")
+		if localized.Detail != tt.want {
+			t.Errorf("%s: detail = %q, want %q", tt.name, localized.Detail, tt.want)
+		}
+		if localized.Code != problem.Code || localized.Type != problem.Type || localized.Title == problem.Title {
+			t.Errorf("%s: localized %+v from %+v", tt.name, localized, problem)
+		}
+		if problem.Detail != tt.err.Error() {
+			t.Errorf("%s: Localize modified the problem: %+v", tt.name, problem)
+		}
+		if english := problem.Localize("fr"); english.Detail != tt.err.Error() {
+			t.Errorf("%s: fallback detail = %q, want %q", tt.name, english.Detail, tt.err.Error())
+		}
+	}
+
+	// Validación: también se traducen los mensajes de cada campo
+	problem, _ := NewProblem(validateCreate("", "Gomez", "bad", "+541145551234"))
+	localized := problem.Localize("es")
+	if got := localized.Errors["email"]; len(got) != 1 || got[0] != "debe ser un email válido" {
+		t.Errorf("email errors = %v", got)
+	}
+	if problem.Errors["email"][0] != "must be a valid email address" {
+		t.Errorf("Localize modified the errors: %v", problem.Errors)
+	}
+
+	// Los errores internos solo tienen el detalle genérico
+	internal := InternalProblem("error creating user").Localize("es")
+	if internal.Detail != "error al crear el usuario" || internal.Code != "INTERNAL_ERROR" {
+		t.Errorf("internal problem = %+v", internal)
+	}
+}
+
+func TestLocalizeResponse(t *testing.T) {
+	original := response.Created("User created successfully", nil, nil)
+	resp := LocalizeResponse(WithHeaders(original, http.Header{"Location": {"/users/1"}}), "es")
+
+	withHeaders, ok := resp.(*HeaderResponse)
+	if !ok || withHeaders.Headers().Get("Location") != "/users/1" {
+		t.Fatalf("LocalizeResponse lost the headers: %#v", resp)
+	}
+	if msg := withHeaders.Response.(*response.SuccessResponse).Message; msg != "Usuario creado correctamente" {
+		t.Errorf("message = %q", msg)
+	}
+	if msg := original.(*response.SuccessResponse).Message; msg != "User created successfully" {
+		t.Errorf("LocalizeResponse modified the original: %q", msg)
+	}
+
+	conflict := errorResponse(NewErrAlreadyExists("email", "ana@example.com"), "error creating user")
+	batch := Batch(http.StatusMultiStatus, "Users created with errors", []BatchItem{{Index: 0, Status: http.StatusConflict, Error: conflict}}, &BatchSummary{Total: 1, Failed: 1})
+	localized := LocalizeResponse(batch, "es").(*BatchResponse)
+	if localized.Message != "Usuarios creados con errores" || localized.Data[0].Error.Error() != "ya existe un usuario con email ana@example.com" {
+		t.Errorf("batch = %q, %q", localized.Message, localized.Data[0].Error.Error())
+	}
+	if batch.(*BatchResponse).Data[0].Error != conflict {
+		t.Error("LocalizeResponse modified the batch items")
+	}
+}
@@ -0,0 +1,565 @@
+package user
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+
+	"gorm.io/gorm"
+)
+
+// memoryRepository es una implementación en memoria de Repository.
+// Replica el comportamiento del repositorio GORM (filtros, orden, soft delete)
+// para poder levantar el servicio sin base de datos (tests, demos locales).
+type memoryRepository struct {
+	log   *log.Logger
+	mu    sync.RWMutex
+	users map[string]domain.User
+}
+
+func NewMemoryRepository(log *log.Logger) Repository {
+	return &memoryRepository{
+		log:   log,
+		users: make(map[string]domain.User),
+	}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, user *domain.User) error {
+	r.log.Println("---- Creating user in memory ----")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.insert(user); err != nil {
+		return err
+	}
+	r.log.Println("User created with ID: ", user.ID)
+	return nil
+}
+
+// CreateBatch replica la semántica del repositorio GORM: en modo atomic,
+// si un usuario falla se deshacen los ya insertados y el resto queda con ErrBatchAborted
+func (r *memoryRepository) CreateBatch(ctx context.Context, users []*domain.User, atomic bool) []error {
+	r.log.Printf("---- Creating %d users in memory (atomic: %t) ----", len(users), atomic)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(users))
+	for i, user := range users {
+		errs[i] = r.insert(user)
+		if errs[i] == nil || !atomic {
+			continue
+		}
+
+		// ↩️ Rollback: sacamos los usuarios que ya habíamos insertado
+		for j := 0; j < i; j++ {
+			delete(r.users, users[j].ID)
+		}
+		for j := range errs {
+			if j != i {
+				errs[j] = ErrBatchAborted
+			}
+		}
+		break
+	}
+	return errs
+}
+
+// insert agrega el usuario; requiere tener tomado r.mu
+func (r *memoryRepository) insert(user *domain.User) error {
+	// 🎯 Mismo hook que ejecuta GORM antes de insertar (genera el UUID)
+	if err := user.BeforeCreate(nil); err != nil {
+		r.log.Println("Error creating user: ", err)
+		return ErrUserNotCreated
+	}
+
+	if _, ok := r.users[user.ID]; ok {
+		r.log.Println("Error creating user: duplicated ID ", user.ID)
+		return NewErrAlreadyExists("id", user.ID)
+	}
+
+	if err := r.checkEmailAvailable(user.Email, ""); err != nil {
+		return err
+	}
+
+	// GORM solo completa los timestamps si vienen vacíos
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = now
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryRepository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
+	r.mu.RLock()
+	users, err := r.filter(filters)
+	r.mu.RUnlock()
+	if err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+
+	if terms := searchTerms(filters.Query); len(terms) > 0 && len(filters.Sort) == 0 {
+		sortByRelevance(users, terms)
+	} else if err := sortUsers(users, filters.Sort); err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+
+	return paginate(users, offset, limit), nil
+}
+
+func (r *memoryRepository) GetAllByCursor(ctx context.Context, filters Filters, after *Cursor, limit int) ([]domain.User, error) {
+	r.mu.RLock()
+	users, err := r.filter(filters)
+	r.mu.RUnlock()
+	if err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+
+	// Orden por defecto: created_at desc, id desc
+	if err := sortUsers(users, nil); err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		start := sort.Search(len(users), func(i int) bool {
+			u := users[i]
+			return u.CreatedAt.Before(after.CreatedAt) || (u.CreatedAt.Equal(after.CreatedAt) && u.ID < after.ID)
+		})
+		users = users[start:]
+	}
+
+	return paginate(users, 0, limit), nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted.Valid {
+		r.log.Printf("No user found with ID: %s", id)
+		return nil, NewErrNotFound(id)
+	}
+	return &user, nil
+}
+
+func (r *memoryRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lower := strings.ToLower(email)
+	for _, u := range r.users {
+		if !u.Deleted.Valid && strings.ToLower(u.Email) == lower {
+			return &u, nil
+		}
+	}
+	r.log.Printf("No user found with email: %s", email)
+	return nil, NewErrEmailNotFound(email)
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, id string, version *Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted.Valid {
+		r.log.Printf("No user found with ID: %s", id)
+		return NewErrNotFound(id)
+	}
+	if version != nil && VersionOf(user) != *version {
+		r.log.Printf("Version mismatch for user %s: expected %d", id, *version)
+		return ErrVersionMismatch
+	}
+
+	// 💡 Soft delete: igual que GORM, solo marcamos la fecha de borrado
+	user.Deleted = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.users[id] = user
+	return nil
+}
+
+func (r *memoryRepository) Restore(ctx context.Context, id string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		r.log.Printf("No deleted user found with ID: %s", id)
+		return nil, NewErrNotFound(id)
+	}
+	if !user.Deleted.Valid {
+		r.log.Printf("User %s is not deleted", id)
+		return nil, ErrUserNotInTrash
+	}
+
+	// El email sigue reservado mientras el usuario está en la papelera: no puede haber conflicto
+	user.Deleted = gorm.DeletedAt{}
+	user.UpdatedAt = VersionOf(user).next(time.Now())
+	r.users[id] = user
+	return &user, nil
+}
+
+func (r *memoryRepository) Purge(ctx context.Context, id string, version *Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		r.log.Printf("No user found with ID: %s", id)
+		return NewErrNotFound(id)
+	}
+	if version != nil && VersionOf(user) != *version {
+		r.log.Printf("Version mismatch for user %s: expected %d", id, *version)
+		return ErrVersionMismatch
+	}
+
+	delete(r.users, id)
+	return nil
+}
+
+func (r *memoryRepository) Update(ctx context.Context, id string, version *Version, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deleted.Valid {
+		r.log.Printf("No user found with ID: %s", id)
+		return nil, NewErrNotFound(id)
+	}
+	if version != nil && VersionOf(user) != *version {
+		r.log.Printf("Version mismatch for user %s: expected %d", id, *version)
+		return nil, ErrVersionMismatch
+	}
+
+	if email != nil {
+		if err := r.checkEmailAvailable(*email, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if firstName != nil {
+		user.FirstName = *firstName
+	}
+	if lastName != nil {
+		user.LastName = *lastName
+	}
+	if email != nil {
+		user.Email = *email
+	}
+	if phone != nil {
+		user.Phone = *phone
+	}
+	// 🎯 La versión siempre avanza, aunque dos escrituras caigan en el mismo milisegundo
+	user.UpdatedAt = VersionOf(user).next(time.Now())
+
+	r.users[id] = user
+	return &user, nil
+}
+
+func (r *memoryRepository) UpdateMany(ctx context.Context, filters Filters, firstName, lastName, phone *string, dryRun bool) (*BulkResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		r.log.Println("Error updating users: ", err)
+		return nil, err
+	}
+
+	result := &BulkResult{Matched: int64(len(users)), DryRun: dryRun}
+	now := time.Now()
+	for _, user := range users {
+		// Igual que en la base: solo se tocan los usuarios en los que cambia algún campo
+		if (firstName == nil || user.FirstName == *firstName) &&
+			(lastName == nil || user.LastName == *lastName) &&
+			(phone == nil || user.Phone == *phone) {
+			continue
+		}
+		result.Affected++
+		if dryRun {
+			continue
+		}
+
+		if firstName != nil {
+			user.FirstName = *firstName
+		}
+		if lastName != nil {
+			user.LastName = *lastName
+		}
+		if phone != nil {
+			user.Phone = *phone
+		}
+		user.UpdatedAt = VersionOf(user).next(now)
+		r.users[user.ID] = user
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) DeleteMany(ctx context.Context, filters Filters, dryRun bool) (*BulkResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		r.log.Println("Error deleting users: ", err)
+		return nil, err
+	}
+
+	result := &BulkResult{Matched: int64(len(users)), Affected: int64(len(users)), DryRun: dryRun}
+	if dryRun {
+		return result, nil
+	}
+
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	for _, user := range users {
+		user.Deleted = deleted
+		r.users[user.ID] = user
+	}
+	return result, nil
+}
+
+func (r *memoryRepository) Count(ctx context.Context, filters Filters) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		r.log.Println("Error counting users: ", err)
+		return 0, err
+	}
+	return int64(len(users)), nil
+}
+
+func (r *memoryRepository) Stats(ctx context.Context, filters Filters) (*CollectionStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users, err := r.filter(filters)
+	if err != nil {
+		r.log.Println("Error counting users: ", err)
+		return nil, err
+	}
+
+	stats := &CollectionStats{Count: int64(len(users))}
+	for _, u := range users {
+		if u.UpdatedAt.After(stats.LastModified) {
+			stats.LastModified = u.UpdatedAt
+		}
+	}
+	return stats, nil
+}
+
+// checkEmailAvailable replica el índice único sobre LOWER(email) de la base.
+// Debe llamarse con el lock de escritura tomado.
+func (r *memoryRepository) checkEmailAvailable(email, excludeID string) error {
+	lower := strings.ToLower(email)
+	for id, u := range r.users {
+		if id != excludeID && strings.ToLower(u.Email) == lower {
+			r.log.Printf("Email already in use: %s", email)
+			return NewErrAlreadyExists("email", email)
+		}
+	}
+	return nil
+}
+
+// filter devuelve una copia de los usuarios no borrados (o solo los borrados,
+// si filters.Deleted) que cumplen los filtros.
+// Debe llamarse con el lock tomado.
+func (r *memoryRepository) filter(filters Filters) ([]domain.User, error) {
+	for _, c := range filters.Conditions {
+		if _, err := filterColumn(c); err != nil {
+			return nil, err
+		}
+	}
+
+	users := make([]domain.User, 0, len(r.users))
+	for _, u := range r.users {
+		if u.Deleted.Valid != filters.Deleted || !matchFilters(u, filters) {
+			continue
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+// matchFilters replica la semántica de applyFilters: LIKE '%valor%' sin distinguir mayúsculas
+// para los filtros simples y el operador correspondiente para las Conditions
+func matchFilters(u domain.User, filters Filters) bool {
+	if len(filters.IDs) > 0 && !slices.Contains(filters.IDs, u.ID) {
+		return false
+	}
+
+	if !containsFold(u.FirstName, filters.FirstName) ||
+		!containsFold(u.LastName, filters.LastName) ||
+		!containsFold(u.Email, filters.Email) ||
+		!containsFold(u.Phone, filters.Phone) {
+		return false
+	}
+
+	if !inRange(u.CreatedAt, filters.CreatedAfter, filters.CreatedBefore) ||
+		!inRange(u.UpdatedAt, filters.UpdatedAfter, filters.UpdatedBefore) {
+		return false
+	}
+
+	if terms := searchTerms(filters.Query); len(terms) > 0 && relevance(u, terms) == 0 {
+		return false
+	}
+
+	for _, c := range filters.Conditions {
+		if !matchCondition(fieldValue(u, c.Field), c) {
+			return false
+		}
+	}
+	return true
+}
+
+// inRange replica los filtros de fecha exclusivos: after < t < before
+func inRange(t time.Time, after, before *time.Time) bool {
+	if after != nil && !t.After(*after) {
+		return false
+	}
+	if before != nil && !t.Before(*before) {
+		return false
+	}
+	return true
+}
+
+func matchCondition(value string, c Condition) bool {
+	value = strings.ToLower(value)
+	switch c.Op {
+	case OpContains:
+		return strings.Contains(value, strings.ToLower(c.Values[0]))
+	case OpPrefix:
+		return strings.HasPrefix(value, strings.ToLower(c.Values[0]))
+	case OpEq:
+		return value == strings.ToLower(c.Values[0])
+	case OpIn:
+		for _, v := range c.Values {
+			if value == strings.ToLower(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldValue devuelve el valor de un campo filtrable por su nombre JSON
+func fieldValue(u domain.User, field string) string {
+	switch field {
+	case "first_name":
+		return u.FirstName
+	case "last_name":
+		return u.LastName
+	case "email":
+		return u.Email
+	case "phone":
+		return u.Phone
+	}
+	return ""
+}
+
+func containsFold(value, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(filter))
+}
+
+// sortUsers replica applySort: sin criterios ordena por created_at desc, con criterios desempata por id
+func sortUsers(users []domain.User, fields []SortField) error {
+	if len(fields) == 0 {
+		sort.SliceStable(users, func(i, j int) bool {
+			if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+				return users[i].CreatedAt.After(users[j].CreatedAt)
+			}
+			return users[i].ID > users[j].ID
+		})
+		return nil
+	}
+
+	for _, f := range fields {
+		if _, err := sortColumn(f.Field); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		for _, f := range fields {
+			c := compareField(users[i], users[j], f.Field)
+			if c == 0 {
+				continue
+			}
+			if f.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return users[i].ID < users[j].ID
+	})
+	return nil
+}
+
+// sortByRelevance replica relevanceOrder: puntaje desc, created_at desc, id desc
+func sortByRelevance(users []domain.User, terms []string) {
+	scores := make(map[string]int, len(users))
+	for _, u := range users {
+		scores[u.ID] = relevance(u, terms)
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		if si, sj := scores[users[i].ID], scores[users[j].ID]; si != sj {
+			return si > sj
+		}
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.After(users[j].CreatedAt)
+		}
+		return users[i].ID > users[j].ID
+	})
+}
+
+func compareField(a, b domain.User, field string) int {
+	switch field {
+	// Los campos de texto se comparan en minúsculas, como applySort (ver textSortFields)
+	case "first_name":
+		return strings.Compare(strings.ToLower(a.FirstName), strings.ToLower(b.FirstName))
+	case "last_name":
+		return strings.Compare(strings.ToLower(a.LastName), strings.ToLower(b.LastName))
+	case "email":
+		return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+	case "phone":
+		return strings.Compare(strings.ToLower(a.Phone), strings.ToLower(b.Phone))
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	return 0
+}
+
+// paginate replica LIMIT/OFFSET de GORM: limit negativo significa sin límite
+func paginate(users []domain.User, offset, limit int) []domain.User {
+	if offset > 0 {
+		if offset >= len(users) {
+			return []domain.User{}
+		}
+		users = users[offset:]
+	}
+	if limit >= 0 && limit < len(users) {
+		users = users[:limit]
+	}
+	return users
+}
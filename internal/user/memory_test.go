@@ -0,0 +1,16 @@
+package user_test
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+	"github.com/NicoJCastro/gocourse_user/internal/user/usertest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	usertest.RunRepositoryTests(t, func(t *testing.T) user.Repository {
+		return user.NewMemoryRepository(log.New(io.Discard, "", 0))
+	})
+}
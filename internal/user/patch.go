@@ -0,0 +1,167 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+// Content-Types de PATCH /users/{id} además de application/json
+const (
+	// ContentTypeMergePatch es JSON Merge Patch (RFC 7396)
+	ContentTypeMergePatch = "application/merge-patch+json"
+	// ContentTypeJSONPatch es JSON Patch (RFC 6902)
+	ContentTypeJSONPatch = "application/json-patch+json"
+)
+
+// PatchOperation es una operación de JSON Patch (RFC 6902)
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// patchableFields son los campos (nombre JSON) que se pueden leer desde un patch y si se pueden escribir
+var patchableFields = map[string]bool{
+	"id":         false,
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"phone":      true,
+}
+
+// ParseJSONPatch decodifica un documento JSON Patch: un array de operaciones
+func ParseJSONPatch(body []byte) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, NewErrPatchOp(0, "", "", "document must be an array of operations")
+	}
+	return ops, nil
+}
+
+// ParseMergePatch convierte un documento JSON Merge Patch en operaciones JSON Patch equivalentes:
+// cada miembro es un replace y cada null un remove
+func ParseMergePatch(body []byte) ([]PatchOperation, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return nil, NewErrPatchOp(0, "", "", "document must be a JSON object")
+	}
+
+	// Orden estable para que los errores sean reproducibles
+	fields := make([]string, 0, len(doc))
+	for field := range doc {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	ops := make([]PatchOperation, 0, len(doc))
+	for _, field := range fields {
+		op := PatchOperation{Op: "replace", Path: "/" + field, Value: doc[field]}
+		if bytes.Equal(bytes.TrimSpace(doc[field]), []byte("null")) {
+			op = PatchOperation{Op: "remove", Path: "/" + field}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// applyPatch aplica las operaciones sobre una copia de u. Si alguna falla no se aplica ninguna.
+// Los campos del usuario son obligatorios: remove los deja vacíos y la validación lo rechaza.
+func applyPatch(u domain.User, ops []PatchOperation) (domain.User, error) {
+	for i, op := range ops {
+		field, err := patchField(i, op, op.Path)
+		if err != nil {
+			return u, err
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			value, err := patchValue(i, op)
+			if err != nil {
+				return u, err
+			}
+			if err := setPatchField(&u, i, op, field, value); err != nil {
+				return u, err
+			}
+		case "remove":
+			if err := setPatchField(&u, i, op, field, ""); err != nil {
+				return u, err
+			}
+		case "test":
+			value, err := patchValue(i, op)
+			if err != nil {
+				return u, err
+			}
+			if patchFieldValue(u, field) != value {
+				return u, NewErrPatchTest(i, op.Path)
+			}
+		case "copy", "move":
+			from, err := patchField(i, op, op.From)
+			if err != nil {
+				return u, err
+			}
+			value := patchFieldValue(u, from)
+			if op.Op == "move" && from != field {
+				if err := setPatchField(&u, i, op, from, ""); err != nil {
+					return u, err
+				}
+			}
+			if err := setPatchField(&u, i, op, field, value); err != nil {
+				return u, err
+			}
+		default:
+			return u, NewErrPatchOp(i, op.Op, op.Path, "unknown operation")
+		}
+	}
+	return u, nil
+}
+
+// patchField traduce un JSON Pointer ("/first_name") al nombre del campo
+func patchField(i int, op PatchOperation, pointer string) (string, error) {
+	field := strings.TrimPrefix(pointer, "/")
+	if !strings.HasPrefix(pointer, "/") || strings.Contains(field, "/") {
+		return "", NewErrPatchOp(i, op.Op, pointer, "path must point to a user field, e.g. /email")
+	}
+	if _, ok := patchableFields[field]; !ok {
+		return "", NewErrPatchOp(i, op.Op, pointer, "unknown field")
+	}
+	return field, nil
+}
+
+// patchValue exige que el valor de la operación sea un string
+func patchValue(i int, op PatchOperation) (string, error) {
+	var value string
+	if len(op.Value) == 0 || json.Unmarshal(op.Value, &value) != nil {
+		return "", NewErrPatchOp(i, op.Op, op.Path, "value must be a string")
+	}
+	return value, nil
+}
+
+func patchFieldValue(u domain.User, field string) string {
+	if field == "id" {
+		return u.ID
+	}
+	return fieldValue(u, field)
+}
+
+func setPatchField(u *domain.User, i int, op PatchOperation, field, value string) error {
+	if !patchableFields[field] {
+		return NewErrPatchOp(i, op.Op, "/"+field, "field is read-only")
+	}
+
+	switch field {
+	case "first_name":
+		u.FirstName = value
+	case "last_name":
+		u.LastName = value
+	case "email":
+		u.Email = value
+	case "phone":
+		u.Phone = value
+	}
+	return nil
+}
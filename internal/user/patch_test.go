@@ -0,0 +1,98 @@
+package user
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+func TestApplyPatch(t *testing.T) {
+	stored := domain.User{ID: "u1", FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234"}
+
+	tests := []struct {
+		name    string
+		ops     string
+		want    domain.User
+		wantErr error
+	}{
+		{
+			name: "replace and add",
+			ops:  `[{"op":"replace","path":"/first_name","value":"Ana María"},{"op":"add","path":"/phone","value":"+541145559876"}]`,
+			want: domain.User{ID: "u1", FirstName: "Ana María", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145559876"},
+		},
+		{
+			name: "test passes",
+			ops:  `[{"op":"test","path":"/email","value":"ana@example.com"},{"op":"replace","path":"/email","value":"ana@example.org"}]`,
+			want: domain.User{ID: "u1", FirstName: "Ana", LastName: "Gomez", Email: "ana@example.org", Phone: "+541145551234"},
+		},
+		{
+			name: "test on id",
+			ops:  `[{"op":"test","path":"/id","value":"u1"}]`,
+			want: stored,
+		},
+		{
+			name: "copy and move",
+			ops:  `[{"op":"copy","from":"/last_name","path":"/first_name"},{"op":"move","from":"/first_name","path":"/last_name"}]`,
+			want: domain.User{ID: "u1", FirstName: "", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234"},
+		},
+		{
+			name: "remove leaves the field empty",
+			ops:  `[{"op":"remove","path":"/phone"}]`,
+			want: domain.User{ID: "u1", FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com"},
+		},
+		{name: "test fails", ops: `[{"op":"replace","path":"/first_name","value":"X"},{"op":"test","path":"/last_name","value":"Diaz"}]`, wantErr: ErrPatchTestFailed},
+		{name: "read-only field", ops: `[{"op":"replace","path":"/id","value":"u2"}]`, wantErr: ErrInvalidPatch},
+		{name: "unknown field", ops: `[{"op":"add","path":"/password","value":"x"}]`, wantErr: ErrInvalidPatch},
+		{name: "nested path", ops: `[{"op":"add","path":"/email/0","value":"x"}]`, wantErr: ErrInvalidPatch},
+		{name: "unknown op", ops: `[{"op":"increment","path":"/phone","value":"1"}]`, wantErr: ErrInvalidPatch},
+		{name: "non-string value", ops: `[{"op":"replace","path":"/phone","value":541145551234}]`, wantErr: ErrInvalidPatch},
+		{name: "missing value", ops: `[{"op":"test","path":"/phone"}]`, wantErr: ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		ops, err := ParseJSONPatch([]byte(tt.ops))
+		if err != nil {
+			t.Fatalf("%s: ParseJSONPatch: %v", tt.name, err)
+		}
+
+		got, err := applyPatch(stored, ops)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMergePatch(t *testing.T) {
+	ops, err := ParseMergePatch([]byte(`{"phone":null,"first_name":"Ana"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PatchOperation{
+		{Op: "replace", Path: "/first_name", Value: []byte(`"Ana"`)},
+		{Op: "remove", Path: "/phone"},
+	}
+	if !reflect.DeepEqual(ops, want) {
+		t.Fatalf("got %+v, want %+v", ops, want)
+	}
+
+	for _, body := range []string{`["a"]`, `null`, `"x"`, `{`} {
+		if _, err := ParseMergePatch([]byte(body)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("ParseMergePatch(%s): expected ErrInvalidPatch, got %v", body, err)
+		}
+	}
+	if _, err := ParseJSONPatch([]byte(`{"op":"add"}`)); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("ParseJSONPatch(object): expected ErrInvalidPatch, got %v", err)
+	}
+}
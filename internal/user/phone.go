@@ -0,0 +1,26 @@
+package user
+
+import (
+	"github.com/nyaruka/phonenumbers"
+)
+
+// normalizePhone convierte un teléfono a E.164 usando defaultCountry (ISO 3166, ej: "AR")
+// para los números escritos en formato local. Si no es un número válido devuelve
+// el valor original y false, para que la validación lo reporte.
+func normalizePhone(phone, defaultCountry string) (string, bool) {
+	num, err := phonenumbers.Parse(phone, defaultCountry)
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		return phone, false
+	}
+	return phonenumbers.Format(num, phonenumbers.E164), true
+}
+
+// normalizePhoneFilter normaliza el filtro de teléfono igual que en la escritura.
+// Los valores parciales (ej: "4555") se dejan como están para buscar por substring.
+func normalizePhoneFilter(phone, defaultCountry string) string {
+	if phone == "" {
+		return phone
+	}
+	normalized, _ := normalizePhone(phone, defaultCountry)
+	return normalized
+}
@@ -0,0 +1,27 @@
+package user
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, country string
+		want        string
+		ok          bool
+	}{
+		{"(011) 4555-1234", "AR", "+541145551234", true},
+		{"011 15 4555-1234", "AR", "+5491145551234", true},
+		{"+54 9 11 4555-1234", "AR", "+5491145551234", true},
+		{"+54 9 11 4555-1234", "", "+5491145551234", true},
+		{"+1 (415) 555-0100", "AR", "+14155550100", true},
+		{"011 4555-1234", "", "011 4555-1234", false},
+		{"4555", "AR", "4555", false},
+		{"abc", "AR", "abc", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizePhone(tt.in, tt.country)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizePhone(%q, %q) = %q, %v; want %q, %v", tt.in, tt.country, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+const (
+	// ContentTypeProblem es el media type de los errores (RFC 9457)
+	ContentTypeProblem = "application/problem+json"
+	// ProblemTypeBase es el prefijo de los type de los problemas de este servicio
+	ProblemTypeBase = "urn:gocourse:user:problem:"
+	// ProblemTypeBlank es el type de los errores HTTP sin más semántica que su status
+	ProblemTypeBlank = "about:blank"
+)
+
+// Problem es una respuesta de error application/problem+json (RFC 9457).
+// Code (estable, ej: USER_NOT_FOUND) es un miembro de extensión para que los clientes no dependan
+// de los mensajes; Field y Errors indican el campo (o los campos) que originaron el error.
+type Problem struct {
+	Type      string              `json:"type"`
+	Code      string              `json:"code"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Field     string              `json:"field,omitempty"`
+	Errors    map[string][]string `json:"errors,omitempty"`
+
+	// err es el error que originó el problema, para traducir su detalle (nil si no hay uno)
+	err error
+}
+
+// problemType describe el problema que corresponde a uno o más errores del paquete.
+// El code es el slug en mayúsculas (ej: user-email-taken -> USER_EMAIL_TAKEN).
+type problemType struct {
+	slug    string
+	title   string
+	status  int
+	matches func(err error) bool
+}
+
+// problemTypes asocia cada error de error.go con su problema; se busca en orden.
+// 💡 Los slugs (y por lo tanto type y code) son parte del contrato de la API: no se renombran.
+var problemTypes = []problemType{
+	{"user-not-found", "User not found", http.StatusNotFound, is(ErrNotFoundBase)},
+	{"user-email-taken", "Email already in use", http.StatusConflict, alreadyExists("email")},
+	{"user-id-taken", "ID already in use", http.StatusConflict, alreadyExists("id")},
+	{"user-already-exists", "User already exists", http.StatusConflict, is(ErrUserAlreadyExists)},
+	{"user-not-in-trash", "User is not in the trash", http.StatusConflict, is(ErrUserNotInTrash)},
+	{"validation-failed", "Validation failed", http.StatusBadRequest, is(ErrValidationFailed)},
+	{"id-required", "ID is required", http.StatusBadRequest, is(ErrIDRequired)},
+	{"first-name-required", "First name is required", http.StatusBadRequest, is(ErrFirstNameRequired)},
+	{"last-name-required", "Last name is required", http.StatusBadRequest, is(ErrLastNameRequired)},
+	{"email-required", "Email is required", http.StatusBadRequest, is(ErrEmailRequired)},
+	{"phone-required", "Phone is required", http.StatusBadRequest, is(ErrPhoneRequired)},
+	{"first-name-empty", "First name cannot be empty", http.StatusBadRequest, is(ErrFirstNameEmpty)},
+	{"last-name-empty", "Last name cannot be empty", http.StatusBadRequest, is(ErrLastNameEmpty)},
+	{"email-empty", "Email cannot be empty", http.StatusBadRequest, is(ErrEmailEmpty)},
+	{"phone-empty", "Phone cannot be empty", http.StatusBadRequest, is(ErrPhoneEmpty)},
+	{"field-required", "At least one field is required", http.StatusBadRequest, is(ErrAtLeastOneFieldRequired)},
+	{"invalid-body", "Invalid request body", http.StatusBadRequest, is(ErrInvalidBody)},
+	{"invalid-parameter", "Invalid parameter", http.StatusBadRequest, is(ErrInvalidParameter)},
+	{"invalid-request-type", "Invalid request type", http.StatusBadRequest, is(ErrInvalidRequestType)},
+	{"invalid-sort", "Invalid sort field", http.StatusBadRequest, is(ErrInvalidSortField)},
+	{"invalid-filter", "Invalid filter", http.StatusBadRequest, is(ErrInvalidFilterParam)},
+	{"invalid-cursor", "Invalid cursor", http.StatusBadRequest, is(ErrInvalidCursor)},
+	{"cursor-with-sort", "Cursor pagination only supports the default order", http.StatusBadRequest, is(ErrCursorWithSort)},
+	{"batch-empty", "Batch is empty", http.StatusBadRequest, is(ErrBatchEmpty)},
+	{"batch-too-large", "Batch is too large", http.StatusBadRequest, is(ErrBatchTooLarge)},
+	{"batch-aborted", "Batch aborted", http.StatusFailedDependency, is(ErrBatchAborted)},
+	{"selection-required", "Selection required", http.StatusBadRequest, is(ErrSelectionRequired)},
+	{"bulk-email-update", "Email cannot be updated in bulk", http.StatusBadRequest, is(ErrBulkEmailUpdate)},
+	{"invalid-patch", "Invalid patch", http.StatusBadRequest, is(ErrInvalidPatch)},
+	{"patch-test-failed", "Patch test failed", http.StatusConflict, is(ErrPatchTestFailed)},
+	{"version-mismatch", "Version mismatch", http.StatusPreconditionFailed, is(ErrVersionMismatch)},
+	{"if-match-required", "If-Match required", http.StatusPreconditionRequired, is(ErrIfMatchRequired)},
+	{"if-match-list", "Invalid If-Match", http.StatusBadRequest, is(ErrIfMatchList)},
+	{"idempotency-key-reused", "Idempotency-Key reused", http.StatusUnprocessableEntity, is(ErrIdempotencyKeyReused)},
+	{"idempotency-keys-busy", "Idempotency-Keys busy", http.StatusServiceUnavailable, is(ErrIdempotencyKeysBusy)},
+	{"user-not-created", "User not created", http.StatusInternalServerError, is(ErrUserNotCreated)},
+	{"user-not-retrieved", "User not retrieved", http.StatusInternalServerError, is(ErrUserNotRetrieved)},
+	{"user-not-updated", "User not updated", http.StatusInternalServerError, is(ErrUserNotUpdated)},
+	{"user-not-deleted", "User not deleted", http.StatusInternalServerError, is(ErrUserNotDeleted)},
+	{"user-not-restored", "User not restored", http.StatusInternalServerError, is(ErrUserNotRestored)},
+	{"user-not-counted", "Users not counted", http.StatusInternalServerError, is(ErrUserNotCounted)},
+	{"invalid-configuration", "Invalid configuration", http.StatusInternalServerError, is(ErrInvalidDefaultLimitConfiguration)},
+}
+
+// internalProblem es el problema de cualquier error inesperado (BD, conexión, etc.)
+var internalProblem = problemType{slug: "internal-error", title: "Internal server error", status: http.StatusInternalServerError}
+
+func is(target error) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// alreadyExists matchea un conflicto con un usuario existente sobre field
+func alreadyExists(field string) func(error) bool {
+	return func(err error) bool {
+		var existsErr *ErrAlreadyExists
+		return errors.As(err, &existsErr) && existsErr.Field == field
+	}
+}
+
+func (pt problemType) typeURI() string {
+	return ProblemTypeBase + pt.slug
+}
+
+func (pt problemType) code() string {
+	return strings.ToUpper(strings.ReplaceAll(pt.slug, "-", "_"))
+}
+
+// problem arma el problema con detail
+func (pt problemType) problem(detail string) *Problem {
+	return &Problem{
+		Type:   pt.typeURI(),
+		Code:   pt.code(),
+		Title:  pt.title,
+		Status: pt.status,
+		Detail: detail,
+	}
+}
+
+// ErrorCatalogEntry describe un error que puede devolver la API (GET /errors)
+type ErrorCatalogEntry struct {
+	Code   string `json:"code"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+// ErrorCatalog lista todos los errores con código propio, incluido INTERNAL_ERROR, con los títulos en lang
+func ErrorCatalog(lang string) []ErrorCatalogEntry {
+	types := append(problemTypes[:len(problemTypes):len(problemTypes)], internalProblem)
+	catalog := make([]ErrorCatalogEntry, 0, len(types))
+	for _, pt := range types {
+		catalog = append(catalog, ErrorCatalogEntry{Code: pt.code(), Type: pt.typeURI(), Title: translate(lang, pt.title), Status: pt.status})
+	}
+	return catalog
+}
+
+// NewProblem traduce err a un problema:
+//   - un *Problem se copia tal cual
+//   - los errores del paquete (sentinelas y tipos que los envuelven) usan su problemType
+//   - cualquier otra response.Response usa about:blank con su status
+//
+// ok es false si err no es ninguno de ellos (un error inesperado).
+func NewProblem(err error) (problem *Problem, ok bool) {
+	var existing *Problem
+	if errors.As(err, &existing) {
+		copied := *existing
+		return &copied, true
+	}
+
+	for _, pt := range problemTypes {
+		if pt.matches(err) {
+			problem = pt.problem(err.Error())
+			problem.err = err
+			problem.addFields(err)
+			return problem, true
+		}
+	}
+
+	var resp response.Response
+	if errors.As(err, &resp) {
+		problem = BlankProblem(resp.StatusCode(), resp.Error())
+		problem.err = err
+		return problem, true
+	}
+	return nil, false
+}
+
+// BlankProblem es un problema sin type propio: solo el status HTTP y el detalle.
+// Su code se deriva del status (ej: BAD_REQUEST).
+func BlankProblem(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	return &Problem{
+		Type:   ProblemTypeBlank,
+		Code:   strings.ToUpper(strings.ReplaceAll(title, " ", "_")),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// InternalProblem es el problema de un error inesperado. detail no debe incluir el error
+// original: puede tener detalles de la base de datos.
+func InternalProblem(detail string) *Problem {
+	return internalProblem.problem(detail)
+}
+
+// Localize devuelve una copia del problema con title, detail y errors en lang.
+// type, code y field no cambian: son para que los clientes identifiquen el error.
+func (p *Problem) Localize(lang string) *Problem {
+	localized := *p
+	localized.Title = translate(lang, p.Title)
+	if p.err == nil {
+		localized.Detail = translate(lang, p.Detail)
+		return &localized
+	}
+
+	localized.Detail = localizeError(lang, p.err)
+	var validationErr *ErrValidation
+	if errors.As(p.err, &validationErr) {
+		localized.Errors = validationErr.localizedFields(lang)
+	}
+	return &localized
+}
+
+// addFields completa las extensiones field y errors según el tipo de err
+func (p *Problem) addFields(err error) {
+	var validationErr *ErrValidation
+	var patchErr *ErrPatchOp
+	var testErr *ErrPatchTest
+	var existsErr *ErrAlreadyExists
+	var paramErr *ErrParameter
+	switch {
+	case errors.As(err, &validationErr):
+		p.Errors = validationErr.Fields
+	case errors.As(err, &patchErr):
+		p.Field = strings.TrimPrefix(patchErr.Path, "/")
+	case errors.As(err, &testErr):
+		p.Field = strings.TrimPrefix(testErr.Path, "/")
+	case errors.As(err, &existsErr):
+		p.Field = existsErr.Field
+	case errors.As(err, &paramErr):
+		p.Field = paramErr.Name
+	}
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+func (p *Problem) GetBody() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+func (p *Problem) GetData() interface{} {
+	return nil
+}
@@ -0,0 +1,211 @@
+package user
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+// sentinels son todos los errores declarados en error.go; TestProblemTypesCoverSentinels
+// falla si se agrega uno allí sin sumarlo acá (y, por lo tanto, sin mapearlo a un problema)
+var sentinels = map[string]error{
+	"ErrFirstNameRequired":                ErrFirstNameRequired,
+	"ErrLastNameRequired":                 ErrLastNameRequired,
+	"ErrEmailRequired":                    ErrEmailRequired,
+	"ErrPhoneRequired":                    ErrPhoneRequired,
+	"ErrUserAlreadyExists":                ErrUserAlreadyExists,
+	"ErrUserNotUpdated":                   ErrUserNotUpdated,
+	"ErrUserNotDeleted":                   ErrUserNotDeleted,
+	"ErrUserNotRestored":                  ErrUserNotRestored,
+	"ErrUserNotInTrash":                   ErrUserNotInTrash,
+	"ErrUserNotCreated":                   ErrUserNotCreated,
+	"ErrUserNotRetrieved":                 ErrUserNotRetrieved,
+	"ErrUserNotCounted":                   ErrUserNotCounted,
+	"ErrInvalidRequestType":               ErrInvalidRequestType,
+	"ErrInvalidBody":                      ErrInvalidBody,
+	"ErrInvalidParameter":                 ErrInvalidParameter,
+	"ErrInvalidDefaultLimitConfiguration": ErrInvalidDefaultLimitConfiguration,
+	"ErrIDRequired":                       ErrIDRequired,
+	"ErrAtLeastOneFieldRequired":          ErrAtLeastOneFieldRequired,
+	"ErrFirstNameEmpty":                   ErrFirstNameEmpty,
+	"ErrLastNameEmpty":                    ErrLastNameEmpty,
+	"ErrEmailEmpty":                       ErrEmailEmpty,
+	"ErrPhoneEmpty":                       ErrPhoneEmpty,
+	"ErrValidationFailed":                 ErrValidationFailed,
+	"ErrInvalidSortField":                 ErrInvalidSortField,
+	"ErrInvalidCursor":                    ErrInvalidCursor,
+	"ErrInvalidFilterParam":               ErrInvalidFilterParam,
+	"ErrCursorWithSort":                   ErrCursorWithSort,
+	"ErrBatchEmpty":                       ErrBatchEmpty,
+	"ErrBatchTooLarge":                    ErrBatchTooLarge,
+	"ErrBatchAborted":                     ErrBatchAborted,
+	"ErrSelectionRequired":                ErrSelectionRequired,
+	"ErrBulkEmailUpdate":                  ErrBulkEmailUpdate,
+	"ErrInvalidPatch":                     ErrInvalidPatch,
+	"ErrPatchTestFailed":                  ErrPatchTestFailed,
+	"ErrVersionMismatch":                  ErrVersionMismatch,
+	"ErrIfMatchRequired":                  ErrIfMatchRequired,
+	"ErrIfMatchList":                      ErrIfMatchList,
+	"ErrIdempotencyKeyReused":             ErrIdempotencyKeyReused,
+	"ErrIdempotencyKeysBusy":              ErrIdempotencyKeysBusy,
+	"ErrNotFoundBase":                     ErrNotFoundBase,
+}
+
+func TestProblemTypesCoverSentinels(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "error.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parsing error.go: %v", err)
+	}
+
+	var declared []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if strings.HasPrefix(name.Name, "Err") {
+					declared = append(declared, name.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(declared)
+
+	if len(declared) != len(sentinels) {
+		t.Errorf("error.go declares %d sentinels, the test knows %d: %v", len(declared), len(sentinels), declared)
+	}
+
+	catalog := make(map[string]ErrorCatalogEntry)
+	for _, entry := range ErrorCatalog(DefaultLanguage) {
+		catalog[entry.Code] = entry
+	}
+
+	slugs := make(map[string]string)
+	for _, name := range declared {
+		sentinel, ok := sentinels[name]
+		if !ok {
+			t.Errorf("%s is not in the sentinels of this test", name)
+			continue
+		}
+		problem, ok := NewProblem(sentinel)
+		if !ok || problem.Type == ProblemTypeBlank || !strings.HasPrefix(problem.Type, ProblemTypeBase) {
+			t.Errorf("%s has no problem type: %+v", name, problem)
+			continue
+		}
+		if other, dup := slugs[problem.Type]; dup {
+			t.Errorf("%s and %s share the problem type %s", name, other, problem.Type)
+		}
+		slugs[problem.Type] = name
+		if problem.Title == "" || problem.Detail != sentinel.Error() {
+			t.Errorf("%s: title %q, detail %q", name, problem.Title, problem.Detail)
+		}
+		if entry, ok := catalog[problem.Code]; !ok || entry.Type != problem.Type || entry.Status != problem.Status {
+			t.Errorf("%s: code %q is not in the catalog as %s (%d)", name, problem.Code, problem.Type, problem.Status)
+		}
+	}
+}
+
+func TestErrorCatalog(t *testing.T) {
+	codes := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, entry := range ErrorCatalog(DefaultLanguage) {
+		if entry.Code == "" || entry.Code != strings.ToUpper(entry.Code) || strings.ContainsAny(entry.Code, "- ") {
+			t.Errorf("code %q is not UPPER_SNAKE_CASE", entry.Code)
+		}
+		if codes[entry.Code] || types[entry.Type] {
+			t.Errorf("duplicated entry %+v", entry)
+		}
+		codes[entry.Code], types[entry.Type] = true, true
+		if entry.Title == "" || http.StatusText(entry.Status) == "" {
+			t.Errorf("incomplete entry %+v", entry)
+		}
+	}
+	for _, code := range []string{"USER_NOT_FOUND", "USER_EMAIL_TAKEN", "VALIDATION_FAILED", "INTERNAL_ERROR"} {
+		if !codes[code] {
+			t.Errorf("%s is not in the catalog", code)
+		}
+	}
+}
+
+func TestErrorResponseHidesInternalErrors(t *testing.T) {
+	err := errors.New("Error 1045 (28000): Access denied for user 'root'@'10.0.0.3'")
+	resp := errorResponse(err, "error creating user")
+
+	problem, ok := resp.(*Problem)
+	if !ok || problem.Status != http.StatusInternalServerError || problem.Code != "INTERNAL_ERROR" {
+		t.Fatalf("errorResponse = %#v, want an INTERNAL_ERROR problem", resp)
+	}
+	body, _ := problem.GetBody()
+	if strings.Contains(string(body), "Access denied") || problem.Detail != "error creating user" {
+		t.Fatalf("internal error leaked: %s", body)
+	}
+}
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		slug   string
+		code   string
+		field  string
+	}{
+		{name: "not found", err: NewErrNotFound("abc"), status: http.StatusNotFound, slug: "user-not-found", code: "USER_NOT_FOUND"},
+		{name: "missing id", err: ErrIDRequired, status: http.StatusBadRequest, slug: "id-required", code: "ID_REQUIRED"},
+		{name: "email taken", err: NewErrAlreadyExists("email", "ana@example.com"), status: http.StatusConflict, slug: "user-email-taken", code: "USER_EMAIL_TAKEN", field: "email"},
+		{name: "id taken", err: NewErrAlreadyExists("id", "abc"), status: http.StatusConflict, slug: "user-id-taken", code: "USER_ID_TAKEN", field: "id"},
+		{name: "invalid sort", err: NewErrInvalidSort("password"), status: http.StatusBadRequest, slug: "invalid-sort", code: "INVALID_SORT"},
+		{name: "invalid parameter", err: NewErrParameter("dry_run", "must be true or false"), status: http.StatusBadRequest, slug: "invalid-parameter", code: "INVALID_PARAMETER", field: "dry_run"},
+		{name: "patch op", err: NewErrPatchOp(0, "replace", "/id", "field is read-only"), status: http.StatusBadRequest, slug: "invalid-patch", code: "INVALID_PATCH", field: "id"},
+		{name: "patch test", err: NewErrPatchTest(1, "/email"), status: http.StatusConflict, slug: "patch-test-failed", code: "PATCH_TEST_FAILED", field: "email"},
+		{name: "batch size", err: NewErrBatchSize(MaxBatchSize + 1), status: http.StatusBadRequest, slug: "batch-too-large", code: "BATCH_TOO_LARGE"},
+		{name: "repository failure", err: ErrUserNotRetrieved, status: http.StatusInternalServerError, slug: "user-not-retrieved", code: "USER_NOT_RETRIEVED"},
+	}
+
+	for _, tt := range tests {
+		problem, ok := NewProblem(tt.err)
+		if !ok {
+			t.Errorf("%s: no problem for %v", tt.name, tt.err)
+			continue
+		}
+		if problem.Status != tt.status || problem.Type != ProblemTypeBase+tt.slug || problem.Code != tt.code || problem.Field != tt.field {
+			t.Errorf("%s: got %+v, want status %d, slug %s, code %s, field %q", tt.name, problem, tt.status, tt.slug, tt.code, tt.field)
+		}
+		if problem.Detail != tt.err.Error() {
+			t.Errorf("%s: detail = %q, want %q", tt.name, problem.Detail, tt.err.Error())
+		}
+	}
+
+	// Validación: todos los campos como extensión errors
+	problem, _ := NewProblem(validateCreate("", "Gomez", "bad", "+541145551234"))
+	if problem.Status != http.StatusBadRequest || len(problem.Errors["first_name"]) == 0 || len(problem.Errors["email"]) == 0 {
+		t.Errorf("validation problem = %+v", problem)
+	}
+
+	// Otras respuestas de error: about:blank con su status
+	problem, ok := NewProblem(response.BadRequest("dry_run must be true or false"))
+	if !ok || problem.Type != ProblemTypeBlank || problem.Status != http.StatusBadRequest || problem.Title != "Bad Request" || problem.Code != "BAD_REQUEST" {
+		t.Errorf("blank problem = %+v", problem)
+	}
+
+	// Un *Problem se copia: completar instance no modifica el original
+	original := BlankProblem(http.StatusConflict, "conflict")
+	copied, _ := NewProblem(WithHeaders(original, http.Header{}))
+	copied.Instance = "/users/1"
+	if original.Instance != "" || copied.Status != http.StatusConflict {
+		t.Errorf("NewProblem did not copy the problem: original %+v, copy %+v", original, copied)
+	}
+
+	if _, ok := NewProblem(errors.New("dial tcp: connection refused")); ok {
+		t.Error("unexpected problem for an unknown error")
+	}
+}
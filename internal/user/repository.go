@@ -1,166 +1,768 @@
-package user
-
-import (
-	"context"
-	"errors"
-	"fmt"
-
-	"github.com/NicoJCastro/gocourse_domain/domain"
-
-	"log"
-	"strings"
-
-	"gorm.io/gorm"
-)
-
-type Repository interface {
-	Create(ctx context.Context, user *domain.User) error
-	GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
-	Get(ctx context.Context, id string) (*domain.User, error)
-	Delete(ctx context.Context, id string) error
-	Update(ctx context.Context, id string, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error)
-	Count(ctx context.Context, filters Filters) (int64, error)
-}
-
-type repository struct {
-	log *log.Logger
-	db  *gorm.DB
-}
-
-func NewRepository(log *log.Logger, db *gorm.DB) Repository {
-	return &repository{log: log, db: db}
-}
-
-func (r *repository) Create(ctx context.Context, user *domain.User) error {
-	r.log.Println("---- Creating user in DB ----")
-	result := r.db.WithContext(ctx).Create(user)
-	if result.Error != nil {
-		r.log.Println("Error creating user: ", result.Error)
-		return ErrUserNotCreated
-	}
-	r.log.Println("User created with ID: ", user.ID)
-	return nil
-}
-
-func (r *repository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
-	var users []domain.User
-	tx := r.db.WithContext(ctx).Model(&users)
-	tx = applyFilters(tx, filters)
-	tx = tx.Limit(limit).Offset(offset)
-	result := tx.Order("created_at desc").Find(&users)
-	if result.Error != nil {
-		r.log.Println("Error getting users: ", result.Error)
-		return nil, ErrUserNotRetrieved
-	}
-
-	return users, nil
-
-}
-
-func (r *repository) Get(ctx context.Context, id string) (*domain.User, error) {
-	user := domain.User{ID: id}
-	result := r.db.WithContext(ctx).First(&user)
-	if result.Error != nil {
-		r.log.Println("Error getting user: ", result.Error)
-		// 🔍 Verificamos si es un error de GORM "record not found"
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, NewErrNotFound(id)
-		}
-		return nil, ErrUserNotRetrieved
-	}
-	return &user, nil
-}
-
-func (r *repository) Delete(ctx context.Context, id string) error {
-	user := domain.User{ID: id}
-	result := r.db.WithContext(ctx).Delete(&user)
-	if result.Error != nil {
-		r.log.Println("Error deleting user: ", result.Error)
-		// 🔍 Verificamos si es un error de GORM "record not found"
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return NewErrNotFound(id)
-		}
-		return ErrUserNotDeleted
-	}
-	if result.RowsAffected == 0 {
-		r.log.Printf("No user found with ID: %s", id)
-		return NewErrNotFound(id)
-	}
-	return nil
-}
-
-func (r *repository) Update(ctx context.Context, id string, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error) {
-	// Construimos el mapa de updates solo con los campos proporcionados
-	updates := make(map[string]interface{})
-	if firstName != nil {
-		updates["first_name"] = *firstName
-	}
-	if lastName != nil {
-		updates["last_name"] = *lastName
-	}
-	if email != nil {
-		updates["email"] = *email
-	}
-	if phone != nil {
-		updates["phone"] = *phone
-	}
-
-	// Ejecutamos la actualización en la base de datos
-	result := r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Updates(updates)
-	if result.Error != nil {
-		r.log.Println("Error updating user: ", result.Error)
-		return nil, ErrUserNotUpdated
-	}
-
-	if result.RowsAffected == 0 {
-		r.log.Printf("No user found with ID: %s", id)
-		return nil, NewErrNotFound(id)
-	}
-
-	// Obtenemos el usuario actualizado después de la operación
-	// Esto asegura que retornamos los datos más recientes (incluyendo timestamps)
-	user, err := r.Get(ctx, id)
-	if err != nil {
-		r.log.Println("Error getting updated user: ", err)
-		return nil, ErrUserNotRetrieved
-	}
-
-	return user, nil
-}
-
-func (r *repository) Count(ctx context.Context, filters Filters) (int64, error) {
-	var count int64
-	tx := r.db.WithContext(ctx).Model(&domain.User{})
-	tx = applyFilters(tx, filters)
-	result := tx.Count(&count)
-	if result.Error != nil {
-		r.log.Println("Error counting users: ", result.Error)
-		return 0, ErrUserNotCounted
-	}
-	return count, nil
-}
-
-func applyFilters(tx *gorm.DB, filters Filters) *gorm.DB {
-
-	if filters.FirstName != "" {
-		filters.FirstName = fmt.Sprintf("%%%s%%", strings.ToLower(filters.FirstName))
-		tx = tx.Where("LOWER(first_name) LIKE ?", filters.FirstName)
-	}
-
-	if filters.LastName != "" {
-		filters.LastName = fmt.Sprintf("%%%s%%", strings.ToLower(filters.LastName))
-		tx = tx.Where("LOWER(last_name) LIKE ?", filters.LastName)
-	}
-
-	if filters.Email != "" {
-		filters.Email = fmt.Sprintf("%%%s%%", strings.ToLower(filters.Email))
-		tx = tx.Where("LOWER(email) LIKE ?", filters.Email)
-	}
-
-	if filters.Phone != "" {
-		filters.Phone = fmt.Sprintf("%%%s%%", strings.ToLower(filters.Phone))
-		tx = tx.Where("LOWER(phone) LIKE ?", filters.Phone)
-	}
-
-	return tx
-}
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	Create(ctx context.Context, user *domain.User) error
+	CreateBatch(ctx context.Context, users []*domain.User, atomic bool) []error
+	GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
+	GetAllByCursor(ctx context.Context, filters Filters, after *Cursor, limit int) ([]domain.User, error)
+	Get(ctx context.Context, id string) (*domain.User, error)
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	Delete(ctx context.Context, id string, version *Version) error
+	Restore(ctx context.Context, id string) (*domain.User, error)
+	Purge(ctx context.Context, id string, version *Version) error
+	Update(ctx context.Context, id string, version *Version, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error)
+	UpdateMany(ctx context.Context, filters Filters, firstName, lastName, phone *string, dryRun bool) (*BulkResult, error)
+	DeleteMany(ctx context.Context, filters Filters, dryRun bool) (*BulkResult, error)
+	Count(ctx context.Context, filters Filters) (int64, error)
+	Stats(ctx context.Context, filters Filters) (*CollectionStats, error)
+}
+
+type repository struct {
+	log *log.Logger
+	db  *gorm.DB
+}
+
+func NewRepository(log *log.Logger, db *gorm.DB) Repository {
+	return &repository{log: log, db: db}
+}
+
+func (r *repository) Create(ctx context.Context, user *domain.User) error {
+	r.log.Println("---- Creating user in DB ----")
+	if err := r.insert(r.db.WithContext(ctx), user); err != nil {
+		return err
+	}
+	r.log.Println("User created with ID: ", user.ID)
+	return nil
+}
+
+// CreateBatch da de alta varios usuarios y devuelve un error por posición (nil si se creó).
+// En modo atomic todo ocurre en una transacción: ante el primer error se revierte el lote
+// y el resto de los usuarios queda con ErrBatchAborted. Si falla la transacción en sí
+// (Begin o Commit), ningún usuario tiene la culpa: todos quedan con ErrUserNotCreated.
+func (r *repository) CreateBatch(ctx context.Context, users []*domain.User, atomic bool) []error {
+	r.log.Printf("---- Creating %d users in DB (atomic: %t) ----", len(users), atomic)
+	errs := make([]error, len(users))
+
+	if !atomic {
+		for i, user := range users {
+			errs[i] = r.insert(r.db.WithContext(ctx), user)
+		}
+		return errs
+	}
+
+	failed := -1
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, user := range users {
+			if err := r.insert(tx, user); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return errs
+	}
+
+	r.log.Println("Error creating users, batch rolled back: ", err)
+	if failed < 0 {
+		for i := range errs {
+			errs[i] = ErrUserNotCreated
+		}
+		return errs
+	}
+	for i := range errs {
+		errs[i] = ErrBatchAborted
+	}
+	errs[failed] = err
+	return errs
+}
+
+// insert crea el usuario con db (la conexión o una transacción) traduciendo los conflictos de email
+func (r *repository) insert(db *gorm.DB, user *domain.User) error {
+	// Un ID elegido por el cliente (PUT) no puede pisar a otro usuario, aunque esté borrado
+	if user.ID != "" {
+		if err := r.checkIDAvailable(db, user.ID); err != nil {
+			return err
+		}
+	}
+
+	// 🔍 Chequeo previo para devolver un error claro; el índice único cubre las carreras
+	if err := r.checkEmailAvailable(db, user.Email, ""); err != nil {
+		return err
+	}
+
+	result := db.Create(user)
+	if result.Error != nil {
+		r.log.Println("Error creating user: ", result.Error)
+		if r.isDuplicatedKey(result.Error) {
+			return NewErrAlreadyExists("email", user.Email)
+		}
+		return ErrUserNotCreated
+	}
+	return nil
+}
+
+func (r *repository) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
+	var users []domain.User
+	tx, err := applyFilters(r.db.WithContext(ctx).Model(&users), filters)
+	if err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+	tx, err = applySort(tx, filters)
+	if err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+	tx = tx.Limit(limit).Offset(offset)
+	result := tx.Find(&users)
+	if result.Error != nil {
+		r.log.Println("Error getting users: ", result.Error)
+		return nil, ErrUserNotRetrieved
+	}
+
+	return users, nil
+
+}
+
+// GetAllByCursor pagina por keyset: devuelve los usuarios posteriores a after en el orden
+// created_at desc, id desc. A diferencia de OFFSET, la consulta usa el índice (created_at, id)
+// y no saltea ni repite filas si se insertan usuarios durante el recorrido.
+func (r *repository) GetAllByCursor(ctx context.Context, filters Filters, after *Cursor, limit int) ([]domain.User, error) {
+	var users []domain.User
+	tx, err := applyFilters(r.db.WithContext(ctx).Model(&users), filters)
+	if err != nil {
+		r.log.Println("Error getting users: ", err)
+		return nil, err
+	}
+	if after != nil {
+		tx = tx.Where("(created_at, id) < (?, ?)", after.CreatedAt.UTC(), after.ID)
+	}
+	result := tx.Order("created_at desc").Order("id desc").Limit(limit).Find(&users)
+	if result.Error != nil {
+		r.log.Println("Error getting users: ", result.Error)
+		return nil, ErrUserNotRetrieved
+	}
+
+	return users, nil
+}
+
+func (r *repository) Get(ctx context.Context, id string) (*domain.User, error) {
+	user := domain.User{ID: id}
+	result := r.db.WithContext(ctx).First(&user)
+	if result.Error != nil {
+		r.log.Println("Error getting user: ", result.Error)
+		// 🔍 Verificamos si es un error de GORM "record not found"
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, NewErrNotFound(id)
+		}
+		return nil, ErrUserNotRetrieved
+	}
+	return &user, nil
+}
+
+func (r *repository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	var user domain.User
+	// 🔍 Coincidencia exacta sin distinguir mayúsculas (usa el índice único de email)
+	result := r.db.WithContext(ctx).Where(lower(r.db, "email")+" = ?", strings.ToLower(email)).First(&user)
+	if result.Error != nil {
+		r.log.Println("Error getting user by email: ", result.Error)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, NewErrEmailNotFound(email)
+		}
+		return nil, ErrUserNotRetrieved
+	}
+	return &user, nil
+}
+
+func (r *repository) Delete(ctx context.Context, id string, version *Version) error {
+	tx := r.db.WithContext(ctx).Where("id = ?", id)
+	if version != nil {
+		tx = whereVersion(tx, *version)
+	}
+
+	result := tx.Delete(&domain.User{})
+	if result.Error != nil {
+		r.log.Println("Error deleting user: ", result.Error)
+		// 🔍 Verificamos si es un error de GORM "record not found"
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return NewErrNotFound(id)
+		}
+		return ErrUserNotDeleted
+	}
+	if result.RowsAffected == 0 {
+		return r.notAffected(r.db.WithContext(ctx), id, version)
+	}
+	return nil
+}
+
+// Restore quita la marca de borrado lógico. Si el usuario existe pero no está borrado
+// devuelve ErrUserNotInTrash.
+func (r *repository) Restore(ctx context.Context, id string) (*domain.User, error) {
+	trash := r.db.WithContext(ctx).Unscoped().Model(&domain.User{}).Where("id = ? AND deleted IS NOT NULL", id)
+
+	// 🎯 Restaurar también es una escritura: la versión avanza respecto de la que tenía al borrarse
+	var result *gorm.DB
+	version, err := r.currentVersion(trash.Session(&gorm.Session{}), id)
+	if err == nil {
+		result = whereVersion(trash, version).
+			Updates(map[string]interface{}{"deleted": nil, "updated_at": version.next(r.db.NowFunc())})
+		err = result.Error
+	}
+	if err != nil && !errors.Is(err, ErrNotFoundBase) {
+		r.log.Println("Error restoring user: ", err)
+		return nil, ErrUserNotRestored
+	}
+	// Sin filas: no está en la papelera o lo restauraron entre medio
+	if err != nil || result.RowsAffected == 0 {
+		if _, err := r.Get(ctx, id); err == nil {
+			r.log.Printf("User %s is not deleted", id)
+			return nil, ErrUserNotInTrash
+		}
+		r.log.Printf("No deleted user found with ID: %s", id)
+		return nil, NewErrNotFound(id)
+	}
+
+	user, err := r.Get(ctx, id)
+	if err != nil {
+		r.log.Println("Error getting restored user: ", err)
+		return nil, ErrUserNotRetrieved
+	}
+	return user, nil
+}
+
+// Purge borra la fila definitivamente (también si estaba en la papelera) y libera su email
+func (r *repository) Purge(ctx context.Context, id string, version *Version) error {
+	tx := r.db.WithContext(ctx).Unscoped().Where("id = ?", id)
+	if version != nil {
+		tx = whereVersion(tx, *version)
+	}
+
+	result := tx.Delete(&domain.User{})
+	if result.Error != nil {
+		r.log.Println("Error purging user: ", result.Error)
+		return ErrUserNotDeleted
+	}
+	if result.RowsAffected == 0 {
+		return r.notAffected(r.db.WithContext(ctx).Unscoped(), id, version)
+	}
+	return nil
+}
+
+// Update actualiza los campos no nil. Con version hace un compare-and-swap:
+// si el usuario cambió desde esa versión devuelve ErrVersionMismatch.
+func (r *repository) Update(ctx context.Context, id string, version *Version, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error) {
+	// Construimos el mapa de updates solo con los campos proporcionados
+	updates := make(map[string]interface{})
+	if firstName != nil {
+		updates["first_name"] = *firstName
+	}
+	if lastName != nil {
+		updates["last_name"] = *lastName
+	}
+	if email != nil {
+		updates["email"] = *email
+	}
+	if phone != nil {
+		updates["phone"] = *phone
+	}
+
+	if email != nil {
+		if err := r.checkEmailAvailable(r.db.WithContext(ctx), *email, id); err != nil {
+			return nil, err
+		}
+	}
+
+	// 🎯 La versión siempre avanza, aunque dos escrituras caigan en el mismo milisegundo.
+	// Sin version también es un compare-and-swap, contra la versión vigente: si otra escritura
+	// gana entre medio se reintenta con la nueva.
+	for attempt := 0; ; attempt++ {
+		expected := version
+		if expected == nil {
+			current, err := r.currentVersion(r.db.WithContext(ctx), id)
+			if err != nil {
+				return nil, err
+			}
+			expected = &current
+		}
+		updates["updated_at"] = expected.next(r.db.NowFunc())
+
+		// Ejecutamos la actualización en la base de datos
+		result := whereVersion(r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id), *expected).Updates(updates)
+		if result.Error != nil {
+			r.log.Println("Error updating user: ", result.Error)
+			if email != nil && r.isDuplicatedKey(result.Error) {
+				return nil, NewErrAlreadyExists("email", *email)
+			}
+			return nil, ErrUserNotUpdated
+		}
+		if result.RowsAffected > 0 {
+			break
+		}
+		if version != nil {
+			return nil, r.notAffected(r.db.WithContext(ctx), id, version)
+		}
+		if attempt == maxUpdateAttempts-1 {
+			r.log.Printf("User %s kept changing while updating it", id)
+			return nil, ErrUserNotUpdated
+		}
+	}
+
+	// Obtenemos el usuario actualizado después de la operación
+	// Esto asegura que retornamos los datos más recientes (incluyendo timestamps)
+	user, err := r.Get(ctx, id)
+	if err != nil {
+		r.log.Println("Error getting updated user: ", err)
+		return nil, ErrUserNotRetrieved
+	}
+
+	return user, nil
+}
+
+// UpdateMany actualiza los usuarios que cumplen los filtros. Solo cuentan como afectados
+// (y solo se tocan) los usuarios en los que cambia al menos un campo.
+func (r *repository) UpdateMany(ctx context.Context, filters Filters, firstName, lastName, phone *string, dryRun bool) (*BulkResult, error) {
+	fields := []struct {
+		column string
+		value  *string
+	}{{"first_name", firstName}, {"last_name", lastName}, {"phone", phone}}
+
+	updates := make(map[string]interface{})
+	var changed []string
+	var args []interface{}
+	for _, f := range fields {
+		if f.value != nil {
+			updates[f.column] = *f.value
+			changed = append(changed, f.column+" <> ?")
+			args = append(args, *f.value)
+		}
+	}
+
+	result := &BulkResult{DryRun: dryRun}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		matched, err := applyFilters(tx.Model(&domain.User{}), filters)
+		if err != nil {
+			return err
+		}
+		if err := matched.Count(&result.Matched).Error; err != nil {
+			r.log.Println("Error counting users: ", err)
+			return ErrUserNotCounted
+		}
+
+		target, err := applyFilters(tx.Model(&domain.User{}), filters)
+		if err != nil {
+			return err
+		}
+		target = target.Where("("+strings.Join(changed, " OR ")+")", args...)
+
+		if dryRun {
+			if err := target.Count(&result.Affected).Error; err != nil {
+				r.log.Println("Error counting users: ", err)
+				return ErrUserNotCounted
+			}
+			return nil
+		}
+
+		// 🎯 Todos pasan a una versión posterior a la más nueva entre ellos: ningún ETag anterior sigue valiendo
+		newest, err := applyFilters(tx.Model(&domain.User{}), filters)
+		if err != nil {
+			return err
+		}
+		var latest domain.User
+		err = newest.Where("("+strings.Join(changed, " OR ")+")", args...).
+			Select("updated_at").Order("updated_at desc").Limit(1).Find(&latest).Error
+		if err != nil {
+			r.log.Println("Error getting last modified: ", err)
+			return ErrUserNotRetrieved
+		}
+		updates["updated_at"] = VersionOf(latest).next(tx.NowFunc())
+
+		updated := target.Updates(updates)
+		if updated.Error != nil {
+			r.log.Println("Error updating users: ", updated.Error)
+			return ErrUserNotUpdated
+		}
+		result.Affected = updated.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteMany borra lógicamente los usuarios que cumplen los filtros
+func (r *repository) DeleteMany(ctx context.Context, filters Filters, dryRun bool) (*BulkResult, error) {
+	result := &BulkResult{DryRun: dryRun}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		matched, err := applyFilters(tx.Model(&domain.User{}), filters)
+		if err != nil {
+			return err
+		}
+		if err := matched.Count(&result.Matched).Error; err != nil {
+			r.log.Println("Error counting users: ", err)
+			return ErrUserNotCounted
+		}
+
+		if dryRun {
+			result.Affected = result.Matched
+			return nil
+		}
+
+		target, err := applyFilters(tx, filters)
+		if err != nil {
+			return err
+		}
+		deleted := target.Delete(&domain.User{})
+		if deleted.Error != nil {
+			r.log.Println("Error deleting users: ", deleted.Error)
+			return ErrUserNotDeleted
+		}
+		result.Affected = deleted.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *repository) Count(ctx context.Context, filters Filters) (int64, error) {
+	var count int64
+	tx, err := applyFilters(r.db.WithContext(ctx).Model(&domain.User{}), filters)
+	if err != nil {
+		r.log.Println("Error counting users: ", err)
+		return 0, err
+	}
+	result := tx.Count(&count)
+	if result.Error != nil {
+		r.log.Println("Error counting users: ", result.Error)
+		return 0, ErrUserNotCounted
+	}
+	return count, nil
+}
+
+// Stats cuenta los usuarios que cumplen los filtros y busca el updated_at más reciente entre ellos
+// en una sola consulta
+func (r *repository) Stats(ctx context.Context, filters Filters) (*CollectionStats, error) {
+	tx, err := applyFilters(r.db.WithContext(ctx).Model(&domain.User{}), filters)
+	if err != nil {
+		r.log.Println("Error counting users: ", err)
+		return nil, err
+	}
+
+	var count int64
+	var newest aggregateTime
+	if err := tx.Select("COUNT(*), MAX(updated_at)").Row().Scan(&count, &newest); err != nil {
+		r.log.Println("Error counting users: ", err)
+		return nil, ErrUserNotCounted
+	}
+	return &CollectionStats{Count: count, LastModified: newest.time}, nil
+}
+
+// aggregateTime lee un timestamp calculado (ej: MAX(updated_at)); NULL es el tiempo cero.
+// 🔧 SQLite guarda las fechas como texto y, sin el tipo de la columna, el driver no las convierte.
+type aggregateTime struct {
+	time time.Time
+}
+
+// sqliteTimeFormats son los formatos en que el driver de SQLite escribe las fechas
+var sqliteTimeFormats = []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999"}
+
+func (t *aggregateTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.time = time.Time{}
+		return nil
+	case time.Time:
+		t.time = v
+		return nil
+	case []byte:
+		value = string(v)
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("unsupported time value %T", value)
+	}
+	for _, layout := range sqliteTimeFormats {
+		if parsed, err := time.Parse(layout, text); err == nil {
+			t.time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported time format %q", text)
+}
+
+// checkEmailAvailable verifica que ningún otro usuario (incluidos los borrados lógicamente,
+// que pueden restaurarse) use el email sin distinguir mayúsculas
+func (r *repository) checkEmailAvailable(db *gorm.DB, email, excludeID string) error {
+	var count int64
+	tx := db.Unscoped().Model(&domain.User{}).Where(lower(db, "email")+" = ?", strings.ToLower(email))
+	if excludeID != "" {
+		tx = tx.Where("id <> ?", excludeID)
+	}
+	if err := tx.Count(&count).Error; err != nil {
+		r.log.Println("Error checking email: ", err)
+		return ErrUserNotRetrieved
+	}
+	if count > 0 {
+		r.log.Printf("Email already in use: %s", email)
+		return NewErrAlreadyExists("email", email)
+	}
+	return nil
+}
+
+// notAffected explica por qué una escritura por ID no modificó filas:
+// el usuario no existe o, si se pidió una versión, cambió desde entonces
+// db define qué usuarios cuentan como existentes (Unscoped incluye la papelera).
+func (r *repository) notAffected(db *gorm.DB, id string, version *Version) error {
+	if version != nil {
+		var count int64
+		if err := db.Model(&domain.User{}).Where("id = ?", id).Count(&count).Error; err == nil && count > 0 {
+			r.log.Printf("Version mismatch for user %s: expected %d", id, *version)
+			return ErrVersionMismatch
+		}
+	}
+	r.log.Printf("No user found with ID: %s", id)
+	return NewErrNotFound(id)
+}
+
+// maxUpdateAttempts limita los reintentos de un Update sin versión cuando otras escrituras le ganan
+const maxUpdateAttempts = 5
+
+// currentVersion devuelve la versión vigente del usuario id entre los que selecciona db
+func (r *repository) currentVersion(db *gorm.DB, id string) (Version, error) {
+	var current domain.User
+	if err := db.Select("updated_at").Where("id = ?", id).Take(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.log.Printf("No user found with ID: %s", id)
+			return 0, NewErrNotFound(id)
+		}
+		r.log.Println("Error getting user version: ", err)
+		return 0, ErrUserNotRetrieved
+	}
+	return VersionOf(current), nil
+}
+
+// whereVersion filtra por el milisegundo de updated_at que corresponde a version
+func whereVersion(tx *gorm.DB, version Version) *gorm.DB {
+	from := version.Time()
+	return tx.Where("updated_at >= ? AND updated_at < ?", from, from.Add(time.Millisecond))
+}
+
+// checkIDAvailable verifica que no exista otro usuario (borrado o no) con ese ID
+func (r *repository) checkIDAvailable(db *gorm.DB, id string) error {
+	var count int64
+	if err := db.Unscoped().Model(&domain.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		r.log.Println("Error checking ID: ", err)
+		return ErrUserNotRetrieved
+	}
+	if count > 0 {
+		r.log.Printf("ID already in use: %s", id)
+		return NewErrAlreadyExists("id", id)
+	}
+	return nil
+}
+
+// isDuplicatedKey detecta violaciones de índice único sin importar el motor
+func (r *repository) isDuplicatedKey(err error) bool {
+	if translator, ok := r.db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+func applyFilters(tx *gorm.DB, filters Filters) (*gorm.DB, error) {
+
+	// 🗑️ Papelera: sin el scope de soft delete y solo las filas borradas
+	if filters.Deleted {
+		tx = tx.Unscoped().Where("deleted IS NOT NULL")
+	}
+
+	if len(filters.IDs) > 0 {
+		tx = tx.Where("id IN ?", filters.IDs)
+	}
+
+	if filters.FirstName != "" {
+		tx = tx.Where(likeExpr(tx, "first_name"), containsPattern(filters.FirstName))
+	}
+
+	if filters.LastName != "" {
+		tx = tx.Where(likeExpr(tx, "last_name"), containsPattern(filters.LastName))
+	}
+
+	if filters.Email != "" {
+		tx = tx.Where(likeExpr(tx, "email"), containsPattern(filters.Email))
+	}
+
+	if filters.Phone != "" {
+		tx = tx.Where(likeExpr(tx, "phone"), containsPattern(filters.Phone))
+	}
+
+	// 🔍 Búsqueda libre: cada término debe aparecer en algún campo
+	for _, term := range searchTerms(filters.Query) {
+		like := containsPattern(term)
+		expr := likeExpr(tx, "first_name") + " OR " + likeExpr(tx, "last_name") + " OR " + likeExpr(tx, "email")
+		args := []interface{}{like, like, like}
+		if digits := phoneTerm(term); digits != "" {
+			expr += " OR phone LIKE ?"
+			args = append(args, "%"+digits+"%")
+		}
+		tx = tx.Where("("+expr+")", args...)
+	}
+
+	// 🔧 Rangos de fechas: en UTC para que SQLite (que guarda texto) compare bien
+	if filters.CreatedAfter != nil {
+		tx = tx.Where("created_at > ?", filters.CreatedAfter.UTC())
+	}
+	if filters.CreatedBefore != nil {
+		tx = tx.Where("created_at < ?", filters.CreatedBefore.UTC())
+	}
+	if filters.UpdatedAfter != nil {
+		tx = tx.Where("updated_at > ?", filters.UpdatedAfter.UTC())
+	}
+	if filters.UpdatedBefore != nil {
+		tx = tx.Where("updated_at < ?", filters.UpdatedBefore.UTC())
+	}
+
+	// 🎯 Filtros con operador: campo[op]=valor
+	for _, c := range filters.Conditions {
+		column, err := filterColumn(c)
+		if err != nil {
+			return nil, err
+		}
+
+		switch c.Op {
+		case OpContains:
+			tx = tx.Where(likeExpr(tx, column), containsPattern(c.Values[0]))
+		case OpPrefix:
+			tx = tx.Where(likeExpr(tx, column), prefixPattern(c.Values[0]))
+		case OpEq:
+			// lower(email) = ? aprovecha el índice único de email
+			tx = tx.Where(lower(tx, column)+" = ?", strings.ToLower(c.Values[0]))
+		case OpIn:
+			values := make([]string, len(c.Values))
+			for i, v := range c.Values {
+				values[i] = strings.ToLower(v)
+			}
+			tx = tx.Where(lower(tx, column)+" IN ?", values)
+		}
+	}
+
+	return tx, nil
+}
+
+// applySort ordena según los campos pedidos, validándolos contra sortableColumns.
+// Sin criterios ordena por relevancia si hay búsqueda libre y, si no, por el orden histórico: created_at desc.
+func applySort(tx *gorm.DB, filters Filters) (*gorm.DB, error) {
+	if len(filters.Sort) == 0 {
+		if terms := searchTerms(filters.Query); len(terms) > 0 {
+			return tx.Order(relevanceOrder(tx, terms)), nil
+		}
+		return tx.Order("created_at desc"), nil
+	}
+
+	for _, field := range filters.Sort {
+		column, err := sortColumn(field.Field)
+		if err != nil {
+			return nil, err
+		}
+		if !textSortFields[field.Field] {
+			tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: field.Desc})
+			continue
+		}
+		// column sale de sortableColumns, nunca del request
+		order := lower(tx, column)
+		if field.Desc {
+			order += " DESC"
+		}
+		tx = tx.Order(order)
+	}
+	// 🔧 Desempate por id para que la paginación sea estable
+	return tx.Order("id"), nil
+}
+
+// relevanceOrder suma el puntaje de cada término (ver search.go) y ordena de mayor a menor,
+// desempatando por el orden histórico
+func relevanceOrder(tx *gorm.DB, terms []string) clause.OrderBy {
+	like := "LIKE ?" + likeEscape(tx)
+	firstName, lastName, email := lower(tx, "first_name"), lower(tx, "last_name"), lower(tx, "email")
+	scores := make([]string, 0, len(terms))
+	vars := make([]interface{}, 0, len(terms)*6)
+	for _, term := range terms {
+		scores = append(scores, fmt.Sprintf(
+			"CASE WHEN %s = ? OR %s = ? OR %s = ? THEN %d "+
+				"WHEN %s %s OR %s %s OR %s %s THEN %d "+
+				"ELSE %d END", firstName, lastName, email, scoreExact,
+			firstName, like, lastName, like, email, like, scorePrefix, scoreContains))
+		prefix := prefixPattern(term)
+		vars = append(vars, term, term, term, prefix, prefix, prefix)
+	}
+
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                "(" + strings.Join(scores, " + ") + ") DESC, created_at DESC, id DESC",
+		Vars:               vars,
+		WithoutParentheses: true,
+	}}
+}
+
+// likeExpr arma la comparación LIKE sin distinguir mayúsculas según el motor.
+// 🔧 En Postgres LIKE distingue mayúsculas, así que usamos ILIKE.
+func likeExpr(tx *gorm.DB, column string) string {
+	if tx.Dialector.Name() == "postgres" {
+		return column + " ILIKE ?" + likeEscape(tx)
+	}
+	return lower(tx, column) + " LIKE ?" + likeEscape(tx)
+}
+
+// sqliteLowerFunc es el LOWER Unicode que registra bootstrap.SQLiteConnection: el nativo de SQLite solo convierte ASCII
+const sqliteLowerFunc = "unicode_lower"
+
+// lower pasa column a minúsculas con la función Unicode del motor.
+// 💡 En SQLite es la misma expresión del índice único de email, así las búsquedas por email lo usan.
+func lower(tx *gorm.DB, column string) string {
+	if tx.Dialector.Name() == "sqlite" {
+		return sqliteLowerFunc + "(" + column + ")"
+	}
+	return "LOWER(" + column + ")"
+}
+
+// likeEscaper escapa los comodines de LIKE: el valor del usuario se compara literalmente,
+// igual que en el repositorio en memoria (ej: email[prefix]=a_ no encuentra "ab...")
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern es el patrón LIKE de "contiene value", en minúsculas
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(value)) + "%"
+}
+
+// prefixPattern es el patrón LIKE de "empieza con value", en minúsculas
+func prefixPattern(value string) string {
+	return likeEscaper.Replace(strings.ToLower(value)) + "%"
+}
+
+// likeEscape declara la barra invertida como escape de los patrones.
+// 🔧 En los literales de MySQL la barra invertida también escapa, así que va duplicada.
+func likeEscape(tx *gorm.DB) string {
+	if tx.Dialector.Name() == "mysql" {
+		return ` ESCAPE '\\'`
+	}
+	return ` ESCAPE '\'`
+}
@@ -0,0 +1,120 @@
+package user_test
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+	"github.com/NicoJCastro/gocourse_user/internal/user/usertest"
+	"github.com/NicoJCastro/gocourse_user/pkg/bootstrap"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestRepositoryMySQL corre la suite contra MySQL.
+// Requiere MYSQL_TEST_DSN, ej: root:root@(localhost:3320)/go_course_user_test?parseTime=True&loc=Local
+func TestRepositoryMySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to mysql: %v", err)
+	}
+	if err := bootstrap.Migrate(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	usertest.RunRepositoryTests(t, gormFactory(db))
+}
+
+// TestRepositoryPostgres corre la suite contra un Postgres levantado como proceso local
+func TestRepositoryPostgres(t *testing.T) {
+	dsn := usertest.StartPostgres(t)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	if err := bootstrap.Migrate(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	usertest.RunRepositoryTests(t, gormFactory(db))
+}
+
+// TestRepositorySQLite corre la suite contra una base SQLite nueva por cada subtest
+func TestRepositorySQLite(t *testing.T) {
+	usertest.RunRepositoryTests(t, func(t *testing.T) user.Repository {
+		db, err := bootstrap.SQLiteConnection(filepath.Join(t.TempDir(), "users.db"))
+		if err != nil {
+			t.Fatalf("opening sqlite: %v", err)
+		}
+		db.Logger = logger.Discard
+		t.Cleanup(func() {
+			if sqlDB, err := db.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		})
+		return user.NewRepository(log.New(io.Discard, "", 0), db)
+	})
+}
+
+// 🎯 El índice de email usa unicode_lower, no un lower redefinido: otro cliente de SQLite
+// evalúa lower con el nativo y vería el índice inconsistente
+func TestSQLiteEmailIndex(t *testing.T) {
+	db, err := bootstrap.SQLiteConnection(filepath.Join(t.TempDir(), "users.db"))
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	db.Logger = logger.Discard
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	var builtin, unicode string
+	if err := db.Raw("SELECT lower('ÁLVAREZ'), unicode_lower('ÁLVAREZ')").Row().Scan(&builtin, &unicode); err != nil {
+		t.Fatalf("calling lower: %v", err)
+	}
+	if builtin != "Álvarez" || unicode != "álvarez" {
+		t.Fatalf("lower = %q, unicode_lower = %q", builtin, unicode)
+	}
+
+	// Una base creada con el índice anterior se migra al nuevo
+	if err := db.Exec("CREATE UNIQUE INDEX idx_users_email_lower ON users (LOWER(email))").Error; err != nil {
+		t.Fatalf("creating the previous index: %v", err)
+	}
+	if err := bootstrap.Migrate(db); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	var indexes []string
+	if err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND sql LIKE '%email%'").Scan(&indexes).Error; err != nil {
+		t.Fatalf("listing indexes: %v", err)
+	}
+	if len(indexes) != 1 || !strings.Contains(indexes[0], "unicode_lower(email)") {
+		t.Fatalf("email indexes = %q, want only the unicode_lower one", indexes)
+	}
+}
+
+// gormFactory vacía la tabla (incluidos los borrados lógicos) antes de cada subtest
+func gormFactory(db *gorm.DB) usertest.Factory {
+	return func(t *testing.T) user.Repository {
+		t.Helper()
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&domain.User{}).Error; err != nil {
+			t.Fatalf("cleaning users table: %v", err)
+		}
+		return user.NewRepository(log.New(io.Discard, "", 0), db)
+	}
+}
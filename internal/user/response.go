@@ -0,0 +1,153 @@
+package user
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+)
+
+// CursorMeta es la metadata de un listado paginado por cursor
+type CursorMeta struct {
+	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CursorResponse es la respuesta exitosa de un listado paginado por cursor.
+// Es equivalente a response.SuccessResponse pero con CursorMeta en lugar de meta.Meta.
+type CursorResponse struct {
+	Message string      `json:"message"`
+	Status  int         `json:"status"`
+	Data    interface{} `json:"data"`
+	Meta    *CursorMeta `json:"meta"`
+}
+
+// CursorPage responde 200 con una página del listado y el cursor de la siguiente
+func CursorPage(msg string, data interface{}, meta *CursorMeta) response.Response {
+	return &CursorResponse{
+		Message: msg,
+		Status:  http.StatusOK,
+		Data:    data,
+		Meta:    meta,
+	}
+}
+
+func (c *CursorResponse) StatusCode() int {
+	return c.Status
+}
+
+func (c *CursorResponse) GetBody() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (c *CursorResponse) Error() string {
+	return ""
+}
+
+func (c *CursorResponse) GetData() interface{} {
+	return c.Data
+}
+
+// BatchItem es el resultado de un elemento de una operación masiva: Data si salió bien, Error si no
+type BatchItem struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	Data   interface{}       `json:"data,omitempty"`
+	Error  response.Response `json:"error,omitempty"`
+}
+
+// BatchSummary resume cuántos elementos del lote salieron bien y cuántos fallaron
+type BatchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// BatchResponse es la respuesta de una operación masiva, con el resultado de cada elemento
+type BatchResponse struct {
+	Message string        `json:"message"`
+	Status  int           `json:"status"`
+	Data    []BatchItem   `json:"data"`
+	Meta    *BatchSummary `json:"meta"`
+}
+
+// Batch responde con status y el resultado de cada elemento del lote
+func Batch(status int, msg string, items []BatchItem, summary *BatchSummary) response.Response {
+	return &BatchResponse{
+		Message: msg,
+		Status:  status,
+		Data:    items,
+		Meta:    summary,
+	}
+}
+
+func (b *BatchResponse) StatusCode() int {
+	return b.Status
+}
+
+func (b *BatchResponse) GetBody() ([]byte, error) {
+	return json.Marshal(b)
+}
+
+func (b *BatchResponse) Error() string {
+	if b.Meta != nil && b.Meta.Failed > 0 {
+		return b.Message
+	}
+	return ""
+}
+
+func (b *BatchResponse) GetData() interface{} {
+	return b.Data
+}
+
+// HeaderResponse agrega headers HTTP a otra respuesta sin cambiar su body.
+// Implementa Headers() (httptransport.Headerer) para que el encoder los escriba.
+type HeaderResponse struct {
+	response.Response
+	header http.Header
+}
+
+// WithHeaders envuelve resp para que se responda con header
+func WithHeaders(resp response.Response, header http.Header) response.Response {
+	return &HeaderResponse{Response: resp, header: header}
+}
+
+func (h *HeaderResponse) Headers() http.Header {
+	return h.header
+}
+
+// MarshalJSON serializa la respuesta envuelta tal cual
+func (h *HeaderResponse) MarshalJSON() ([]byte, error) {
+	return h.Response.GetBody()
+}
+
+// Unwrap expone la respuesta envuelta a errors.As (ej: un *Problem)
+func (h *HeaderResponse) Unwrap() error {
+	return h.Response
+}
+
+// StatusResponse es una respuesta sin body, solo con status (ej: 304 Not Modified)
+type StatusResponse struct {
+	Status int
+}
+
+// NotModified responde 304 con los validadores vigentes del recurso
+func NotModified(header http.Header) response.Response {
+	return WithHeaders(&StatusResponse{Status: http.StatusNotModified}, header)
+}
+
+func (s *StatusResponse) StatusCode() int {
+	return s.Status
+}
+
+func (s *StatusResponse) GetBody() ([]byte, error) {
+	return nil, nil
+}
+
+func (s *StatusResponse) Error() string {
+	return ""
+}
+
+func (s *StatusResponse) GetData() interface{} {
+	return nil
+}
@@ -0,0 +1,68 @@
+package user
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+// Puntaje de relevancia de cada término de la búsqueda libre (q)
+const (
+	scoreExact    = 3 // el término es el nombre, el apellido o el email completo
+	scorePrefix   = 2 // algún campo empieza con el término
+	scoreContains = 1 // el término aparece en algún campo
+)
+
+// searchTerms separa la búsqueda en términos en minúsculas; todos deben coincidir
+func searchTerms(q string) []string {
+	return strings.Fields(strings.ToLower(q))
+}
+
+// minPhoneDigits es la cantidad mínima de dígitos para que un término se compare con teléfonos
+const minPhoneDigits = 4
+
+// phoneLike son los términos escritos como teléfono: solo dígitos, +, paréntesis, puntos y guiones
+var phoneLike = regexp.MustCompile(`^[+\d().-]+$`)
+
+// phoneTerm devuelve los dígitos del término para compararlo con teléfonos en E.164.
+// Así "4555-1234" encuentra "+541145551234". Si el término no es un teléfono (ej: "bob2@x.com")
+// o tiene menos de minPhoneDigits dígitos devuelve "" (no compara teléfonos).
+func phoneTerm(term string) string {
+	if !phoneLike.MatchString(term) {
+		return ""
+	}
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, term)
+	if len(digits) < minPhoneDigits {
+		return ""
+	}
+	return digits
+}
+
+// relevance replica en memoria el puntaje que calcula la base. Devuelve 0 si algún término no coincide.
+func relevance(u domain.User, terms []string) int {
+	first, last, email := strings.ToLower(u.FirstName), strings.ToLower(u.LastName), strings.ToLower(u.Email)
+
+	total := 0
+	for _, term := range terms {
+		digits := phoneTerm(term)
+		switch {
+		case first == term || last == term || email == term:
+			total += scoreExact
+		case strings.HasPrefix(first, term) || strings.HasPrefix(last, term) || strings.HasPrefix(email, term):
+			total += scorePrefix
+		case strings.Contains(first, term) || strings.Contains(last, term) || strings.Contains(email, term) ||
+			(digits != "" && strings.Contains(u.Phone, digits)):
+			total += scoreContains
+		default:
+			return 0
+		}
+	}
+	return total
+}
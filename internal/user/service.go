@@ -1,108 +1,485 @@
-package user
-
-import (
-	"context"
-	"fmt"
-	"log"
-
-	"github.com/NicoJCastro/gocourse_domain/domain"
-)
-
-type (
-	Filters struct {
-		FirstName string
-		LastName  string
-		Email     string
-		Phone     string
-	}
-
-	Service interface {
-		Create(ctx context.Context, firstName, lastName, email, phone string) (*domain.User, error)
-		Get(ctx context.Context, id string) (*domain.User, error)
-		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
-		Delete(ctx context.Context, id string) error
-		Update(ctx context.Context, id string, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error)
-		Count(ctx context.Context, filters Filters) (int64, error)
-	}
-	// minúscula porque es privado
-	service struct {
-		log  *log.Logger
-		repo Repository
-	}
-)
-
-func NewService(log *log.Logger, repo Repository) Service {
-	return &service{
-		log:  log,
-		repo: repo,
-	}
-}
-
-func (s service) Create(ctx context.Context, firstName, lastName, email, phone string) (*domain.User, error) {
-	s.log.Println("---- Creating user ----")
-
-	// Validaciones básicas
-	if firstName == "" || lastName == "" || email == "" || phone == "" {
-		s.log.Println("Error: campos vacíos")
-		return nil, fmt.Errorf("todos los campos son requeridos")
-	}
-
-	user := domain.User{
-		FirstName: firstName,
-		LastName:  lastName,
-		Email:     email,
-		Phone:     phone,
-	}
-
-	// Agregamos logging para debug
-	s.log.Printf("Datos a insertar: %+v\n", user)
-
-	// Propagamos el error del repositorio
-	if err := s.repo.Create(ctx, &user); err != nil {
-		s.log.Printf("Error creando usuario: %v\n", err)
-		return nil, err
-	}
-
-	s.log.Printf("Usuario creado exitosamente: %s %s\n", firstName, lastName)
-	return &user, nil
-}
-
-func (s service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
-	s.log.Println("---- Getting all users ----")
-	users, err := s.repo.GetAll(ctx, filters, offset, limit)
-	if err != nil {
-		s.log.Printf("Error getting users: %v\n", err)
-		return nil, err
-	}
-	return users, nil
-}
-
-func (s service) Get(ctx context.Context, id string) (*domain.User, error) {
-	users, err := s.repo.Get(ctx, id)
-	if err != nil {
-		s.log.Printf("Error getting user: %v\n", err)
-		return nil, err
-	}
-	return users, nil
-}
-
-func (s service) Delete(ctx context.Context, id string) error {
-	s.log.Println("---- Deleting user ----")
-	return s.repo.Delete(ctx, id)
-}
-
-func (s service) Update(ctx context.Context, id string, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error) {
-	s.log.Println("---- Updating user ----")
-	// ✅ Retornamos el usuario actualizado del repositorio
-	user, err := s.repo.Update(ctx, id, firstName, lastName, email, phone)
-	if err != nil {
-		s.log.Printf("Error updating user: %v\n", err)
-		return nil, err
-	}
-	s.log.Printf("User updated successfully: %s\n", id)
-	return user, nil
-}
-
-func (s service) Count(ctx context.Context, filters Filters) (int64, error) {
-	return s.repo.Count(ctx, filters)
-}
+package user
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+)
+
+// MaxBatchSize es la cantidad máxima de usuarios por alta masiva
+const MaxBatchSize = 1000
+
+type (
+	Filters struct {
+		// IDs restringe el resultado a esos usuarios (ids=a,b,c)
+		IDs       []string
+		FirstName string
+		LastName  string
+		Email     string
+		Phone     string
+		// Query es la búsqueda libre: cada término debe aparecer en nombre, apellido, email o teléfono
+		Query string
+		// Conditions son los filtros con operador (campo[op]=valor), combinados con AND
+		Conditions []Condition
+		// Rangos de fechas (exclusivos); nil significa sin límite
+		CreatedAfter  *time.Time
+		CreatedBefore *time.Time
+		UpdatedAfter  *time.Time
+		UpdatedBefore *time.Time
+		// Sort solo afecta a GetAll; vacío ordena por relevancia si hay Query o por created_at desc
+		Sort []SortField
+		// Deleted lista la papelera: solo los usuarios borrados lógicamente (GET /users/deleted)
+		Deleted bool
+	}
+
+	Service interface {
+		Create(ctx context.Context, firstName, lastName, email, phone string) (*domain.User, error)
+		CreateBatch(ctx context.Context, users []domain.User, atomic bool) ([]BatchResult, error)
+		UpdateMany(ctx context.Context, filters Filters, firstName, lastName, phone *string, dryRun bool) (*BulkResult, error)
+		DeleteMany(ctx context.Context, filters Filters, dryRun bool) (*BulkResult, error)
+		Get(ctx context.Context, id string) (*domain.User, error)
+		GetByEmail(ctx context.Context, email string) (*domain.User, error)
+		GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error)
+		GetAllByCursor(ctx context.Context, filters Filters, after *Cursor, limit int) ([]domain.User, error)
+		Delete(ctx context.Context, id string, cond *Precondition) error
+		Restore(ctx context.Context, id string) (*domain.User, error)
+		Purge(ctx context.Context, id string, cond *Precondition) error
+		Update(ctx context.Context, id string, cond *Precondition, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error)
+		Replace(ctx context.Context, id string, cond *Precondition, firstName, lastName, email, phone string) (*domain.User, bool, error)
+		Patch(ctx context.Context, id string, cond *Precondition, ops []PatchOperation) (*domain.User, error)
+		Count(ctx context.Context, filters Filters) (int64, error)
+		Stats(ctx context.Context, filters Filters) (*CollectionStats, error)
+	}
+
+	// BatchResult es el resultado de un usuario dentro de un alta masiva: User si se creó, Err si no
+	BatchResult struct {
+		Index int
+		User  *domain.User
+		Err   error
+	}
+
+	// BulkResult informa cuántos usuarios cumplían la selección (Matched) y cuántos cambiaron (Affected).
+	// Con DryRun no se modifica nada y Affected es lo que habría cambiado.
+	BulkResult struct {
+		Matched  int64 `json:"matched"`
+		Affected int64 `json:"affected"`
+		DryRun   bool  `json:"dry_run"`
+	}
+
+	// CollectionStats resume los usuarios que cumplen unos filtros: cuántos son y el updated_at
+	// más reciente entre ellos (cero si no hay ninguno). Es el validador (ETag) de los listados.
+	CollectionStats struct {
+		Count        int64
+		LastModified time.Time
+	}
+
+	// ServiceConfig agrupa las políticas de normalización de datos del servicio
+	ServiceConfig struct {
+		// DefaultCountry (ISO 3166, ej: "AR") se usa para los teléfonos escritos sin prefijo internacional
+		DefaultCountry string
+		// EmailPolicy define cómo se canonicalizan los emails (EmailPolicyBasic por defecto)
+		EmailPolicy string
+	}
+
+	// minúscula porque es privado
+	service struct {
+		log    *log.Logger
+		repo   Repository
+		config ServiceConfig
+	}
+)
+
+func NewService(log *log.Logger, repo Repository, config ServiceConfig) Service {
+	return &service{
+		log:    log,
+		repo:   repo,
+		config: config,
+	}
+}
+
+func (s service) Create(ctx context.Context, firstName, lastName, email, phone string) (*domain.User, error) {
+	s.log.Println("---- Creating user ----")
+
+	// 🔧 Normalizamos email y teléfono antes de validar y guardar
+	email = canonicalizeEmail(email, s.config.EmailPolicy)
+	phone, _ = normalizePhone(phone, s.config.DefaultCountry)
+
+	// Validamos todos los campos de una vez para reportar todos los problemas juntos
+	if err := validateCreate(firstName, lastName, email, phone); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, err
+	}
+
+	user := domain.User{
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+		Phone:     phone,
+	}
+
+	// Agregamos logging para debug
+	s.log.Printf("Datos a insertar: %+v\n", user)
+
+	// Propagamos el error del repositorio
+	if err := s.repo.Create(ctx, &user); err != nil {
+		s.log.Printf("Error creando usuario: %v\n", err)
+		return nil, err
+	}
+
+	s.log.Printf("Usuario creado exitosamente: %s %s\n", firstName, lastName)
+	return &user, nil
+}
+
+// CreateBatch da de alta varios usuarios aplicando las mismas reglas que Create.
+// Con atomic=true se crean todos o ninguno: si uno falla, el resto queda con ErrBatchAborted.
+// El error solo se devuelve cuando el lote en sí es inválido (vacío o demasiado grande).
+func (s service) CreateBatch(ctx context.Context, users []domain.User, atomic bool) ([]BatchResult, error) {
+	s.log.Printf("---- Creating %d users (atomic: %t) ----", len(users), atomic)
+
+	if len(users) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(users) > MaxBatchSize {
+		return nil, NewErrBatchSize(len(users))
+	}
+
+	results := make([]BatchResult, len(users))
+	pending := make([]*domain.User, 0, len(users))
+	positions := make([]int, 0, len(users))
+	emails := make(map[string]bool, len(users))
+	failed := false
+
+	for i := range users {
+		u := users[i]
+		results[i].Index = i
+
+		u.Email = canonicalizeEmail(u.Email, s.config.EmailPolicy)
+		u.Phone, _ = normalizePhone(u.Phone, s.config.DefaultCountry)
+
+		if err := validateCreate(u.FirstName, u.LastName, u.Email, u.Phone); err != nil {
+			results[i].Err = err
+			failed = true
+			continue
+		}
+
+		// 🔍 Dos usuarios del mismo lote con el mismo email: el segundo entra en conflicto
+		key := strings.ToLower(u.Email)
+		if emails[key] {
+			results[i].Err = NewErrAlreadyExists("email", u.Email)
+			failed = true
+			continue
+		}
+		emails[key] = true
+
+		pending = append(pending, &u)
+		positions = append(positions, i)
+	}
+
+	// En modo atomic no llegamos a la BD si algún usuario ya es inválido
+	if atomic && failed {
+		for _, i := range positions {
+			results[i].Err = ErrBatchAborted
+		}
+		return results, nil
+	}
+
+	if len(pending) > 0 {
+		errs := s.repo.CreateBatch(ctx, pending, atomic)
+		for j, i := range positions {
+			if errs[j] != nil {
+				results[i].Err = errs[j]
+				continue
+			}
+			results[i].User = pending[j]
+		}
+	}
+
+	return results, nil
+}
+
+func (s service) GetAll(ctx context.Context, filters Filters, offset, limit int) ([]domain.User, error) {
+	s.log.Println("---- Getting all users ----")
+	filters = s.normalizeFilters(filters)
+	users, err := s.repo.GetAll(ctx, filters, offset, limit)
+	if err != nil {
+		s.log.Printf("Error getting users: %v\n", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s service) GetAllByCursor(ctx context.Context, filters Filters, after *Cursor, limit int) ([]domain.User, error) {
+	s.log.Println("---- Getting users by cursor ----")
+	filters = s.normalizeFilters(filters)
+	users, err := s.repo.GetAllByCursor(ctx, filters, after, limit)
+	if err != nil {
+		s.log.Printf("Error getting users: %v\n", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s service) Get(ctx context.Context, id string) (*domain.User, error) {
+	users, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.log.Printf("Error getting user: %v\n", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s service) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	user, err := s.repo.GetByEmail(ctx, canonicalizeEmail(email, s.config.EmailPolicy))
+	if err != nil {
+		s.log.Printf("Error getting user by email: %v\n", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete borra el usuario; cond es el If-Match de la request (nil si no vino)
+func (s service) Delete(ctx context.Context, id string, cond *Precondition) error {
+	s.log.Println("---- Deleting user ----")
+	return s.repo.Delete(ctx, id, cond.version())
+}
+
+// Restore recupera un usuario de la papelera
+func (s service) Restore(ctx context.Context, id string) (*domain.User, error) {
+	s.log.Println("---- Restoring user ----")
+	user, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		s.log.Printf("Error restoring user: %v\n", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// Purge borra definitivamente al usuario, esté o no en la papelera
+func (s service) Purge(ctx context.Context, id string, cond *Precondition) error {
+	s.log.Println("---- Purging user ----")
+	return s.repo.Purge(ctx, id, cond.version())
+}
+
+// Update actualiza los campos no nil; con cond solo si el usuario sigue en esa versión
+func (s service) Update(ctx context.Context, id string, cond *Precondition, firstName *string, lastName *string, email *string, phone *string) (*domain.User, error) {
+	s.log.Println("---- Updating user ----")
+	if email != nil {
+		canonical := canonicalizeEmail(*email, s.config.EmailPolicy)
+		email = &canonical
+	}
+	if phone != nil {
+		normalized, _ := normalizePhone(*phone, s.config.DefaultCountry)
+		phone = &normalized
+	}
+
+	if err := validateUpdate(firstName, lastName, email, phone); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, err
+	}
+	// ✅ Retornamos el usuario actualizado del repositorio
+	user, err := s.repo.Update(ctx, id, cond.version(), firstName, lastName, email, phone)
+	if err != nil {
+		s.log.Printf("Error updating user: %v\n", err)
+		return nil, err
+	}
+	s.log.Printf("User updated successfully: %s\n", id)
+	return user, nil
+}
+
+// Replace reemplaza el usuario completo; todos los campos son obligatorios.
+// Si el ID no existe se crea el usuario con ese ID (upsert) y el bool devuelto es true.
+// Con cond (If-Match) no hay upsert: el usuario tiene que existir y estar en esa versión.
+func (s service) Replace(ctx context.Context, id string, cond *Precondition, firstName, lastName, email, phone string) (*domain.User, bool, error) {
+	s.log.Println("---- Replacing user ----")
+
+	email = canonicalizeEmail(email, s.config.EmailPolicy)
+	phone, _ = normalizePhone(phone, s.config.DefaultCountry)
+
+	if err := validateCreate(firstName, lastName, email, phone); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, false, err
+	}
+
+	user, err := s.repo.Update(ctx, id, cond.version(), &firstName, &lastName, &email, &phone)
+	if err == nil {
+		s.log.Printf("User replaced successfully: %s\n", id)
+		return user, false, nil
+	}
+	if !errors.Is(err, ErrNotFoundBase) {
+		s.log.Printf("Error replacing user: %v\n", err)
+		return nil, false, err
+	}
+	if cond != nil {
+		// Sin If-Match se crearía el usuario: la precondición sobre un usuario inexistente falla
+		s.log.Printf("Error replacing user: If-Match on missing user %s\n", id)
+		return nil, false, ErrVersionMismatch
+	}
+
+	// 🎯 Upsert: el usuario no existe, lo creamos con el ID que eligió el cliente
+	if err := validateID(id); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, false, err
+	}
+	created := domain.User{
+		ID:        id,
+		FirstName: firstName,
+		LastName:  lastName,
+		Email:     email,
+		Phone:     phone,
+	}
+	if err := s.repo.Create(ctx, &created); err != nil {
+		s.log.Printf("Error creando usuario: %v\n", err)
+		return nil, false, err
+	}
+
+	s.log.Printf("Usuario creado exitosamente: %s\n", id)
+	return &created, true, nil
+}
+
+// Patch aplica operaciones JSON Patch (o un Merge Patch ya convertido) sobre el usuario guardado.
+// Las operaciones se aplican todas o ninguna y solo se escriben los campos que cambian.
+// La escritura es un compare-and-swap contra la versión leída, así nadie la pisa entre medio.
+func (s service) Patch(ctx context.Context, id string, cond *Precondition, ops []PatchOperation) (*domain.User, error) {
+	s.log.Println("---- Patching user ----")
+
+	current, err := s.repo.Get(ctx, id)
+	if err != nil {
+		s.log.Printf("Error getting user: %v\n", err)
+		return nil, err
+	}
+
+	version := VersionOf(*current)
+	if v := cond.version(); v != nil && *v != version {
+		s.log.Printf("Error patching user: version mismatch for %s\n", id)
+		return nil, ErrVersionMismatch
+	}
+
+	patched, err := applyPatch(*current, ops)
+	if err != nil {
+		s.log.Printf("Error applying patch: %v\n", err)
+		return nil, err
+	}
+
+	var firstName, lastName, email, phone *string
+	if patched.FirstName != current.FirstName {
+		firstName = &patched.FirstName
+	}
+	if patched.LastName != current.LastName {
+		lastName = &patched.LastName
+	}
+	if patched.Email != current.Email {
+		canonical := canonicalizeEmail(patched.Email, s.config.EmailPolicy)
+		email = &canonical
+	}
+	if patched.Phone != current.Phone {
+		normalized, _ := normalizePhone(patched.Phone, s.config.DefaultCountry)
+		phone = &normalized
+	}
+
+	// Un patch sin cambios (ej: solo operaciones test) no toca la base
+	if firstName == nil && lastName == nil && email == nil && phone == nil {
+		return current, nil
+	}
+
+	if err := validateUpdate(firstName, lastName, email, phone); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, err
+	}
+
+	user, err := s.repo.Update(ctx, id, &version, firstName, lastName, email, phone)
+	if err != nil {
+		s.log.Printf("Error updating user: %v\n", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateMany actualiza los mismos campos en todos los usuarios que cumplen los filtros.
+// El email no se puede modificar en bloque porque es único por usuario.
+func (s service) UpdateMany(ctx context.Context, filters Filters, firstName, lastName, phone *string, dryRun bool) (*BulkResult, error) {
+	s.log.Printf("---- Updating users in bulk (dry run: %t) ----", dryRun)
+	filters = s.normalizeFilters(filters)
+	if err := checkSelection(filters); err != nil {
+		return nil, err
+	}
+
+	if phone != nil {
+		normalized, _ := normalizePhone(*phone, s.config.DefaultCountry)
+		phone = &normalized
+	}
+	if err := validateUpdate(firstName, lastName, nil, phone); err != nil {
+		s.log.Printf("Error de validación: %v\n", err)
+		return nil, err
+	}
+
+	result, err := s.repo.UpdateMany(ctx, filters, firstName, lastName, phone, dryRun)
+	if err != nil {
+		s.log.Printf("Error updating users: %v\n", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeleteMany borra (lógicamente) todos los usuarios que cumplen los filtros
+func (s service) DeleteMany(ctx context.Context, filters Filters, dryRun bool) (*BulkResult, error) {
+	s.log.Printf("---- Deleting users in bulk (dry run: %t) ----", dryRun)
+	filters = s.normalizeFilters(filters)
+	if err := checkSelection(filters); err != nil {
+		return nil, err
+	}
+
+	result, err := s.repo.DeleteMany(ctx, filters, dryRun)
+	if err != nil {
+		s.log.Printf("Error deleting users: %v\n", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkSelection evita que una operación masiva sin IDs ni filtros alcance a todos los usuarios
+func checkSelection(filters Filters) error {
+	if len(filters.IDs) > MaxBatchSize {
+		return NewErrBatchSize(len(filters.IDs))
+	}
+	if len(filters.IDs) == 0 && filters.FirstName == "" && filters.LastName == "" &&
+		filters.Email == "" && filters.Phone == "" && filters.Query == "" && len(filters.Conditions) == 0 &&
+		filters.CreatedAfter == nil && filters.CreatedBefore == nil &&
+		filters.UpdatedAfter == nil && filters.UpdatedBefore == nil {
+		return ErrSelectionRequired
+	}
+	return nil
+}
+
+func (s service) Count(ctx context.Context, filters Filters) (int64, error) {
+	filters = s.normalizeFilters(filters)
+	return s.repo.Count(ctx, filters)
+}
+
+// Stats devuelve la cantidad de usuarios y el updated_at más reciente bajo los filtros
+func (s service) Stats(ctx context.Context, filters Filters) (*CollectionStats, error) {
+	filters = s.normalizeFilters(filters)
+	return s.repo.Stats(ctx, filters)
+}
+
+// normalizeFilters aplica a los filtros de teléfono la misma normalización que la escritura
+func (s service) normalizeFilters(filters Filters) Filters {
+	filters.Phone = normalizePhoneFilter(filters.Phone, s.config.DefaultCountry)
+
+	conditions := make([]Condition, len(filters.Conditions))
+	for i, c := range filters.Conditions {
+		if c.Field == "phone" {
+			values := make([]string, len(c.Values))
+			for j, v := range c.Values {
+				values[j] = normalizePhoneFilter(v, s.config.DefaultCountry)
+			}
+			c.Values = values
+		}
+		conditions[i] = c
+	}
+	filters.Conditions = conditions
+	return filters
+}
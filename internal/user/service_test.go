@@ -0,0 +1,141 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+)
+
+func newTestService() user.Service {
+	logger := log.New(io.Discard, "", 0)
+	return user.NewService(logger, user.NewMemoryRepository(logger), user.ServiceConfig{DefaultCountry: "AR"})
+}
+
+func TestServicePhoneNormalization(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService()
+
+	created, err := s.Create(ctx, "Ana", "Gomez", "ana@example.com", "(011) 4555-1234")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.Phone != "+541145551234" {
+		t.Fatalf("Create stored phone %q, want +541145551234", created.Phone)
+	}
+
+	phone := "011 15 4555-9876"
+	updated, err := s.Update(ctx, created.ID, nil, nil, nil, nil, &phone)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Phone != "+5491145559876" {
+		t.Fatalf("Update stored phone %q, want +5491145559876", updated.Phone)
+	}
+
+	// 🔍 El filtro acepta el número escrito en otro formato
+	for _, filter := range []string{"+54 9 11 4555-9876", "(011) 15-4555-9876", "9876"} {
+		filters := user.Filters{Phone: filter}
+		users, err := s.GetAll(ctx, filters, 0, 10)
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		count, err := s.Count(ctx, filters)
+		if err != nil {
+			t.Fatalf("Count: %v", err)
+		}
+		if len(users) != 1 || count != 1 {
+			t.Errorf("filter %q matched %d users (count %d), want 1", filter, len(users), count)
+		}
+	}
+}
+
+func TestServiceCreateBatch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService()
+
+	users := []domain.User{
+		{FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "(011) 4555-1234"},
+		{FirstName: "", LastName: "Diaz", Email: "bruno@example.com", Phone: "+541145559876"},
+		// 🔍 Mismo email que el primero una vez canonicalizado
+		{FirstName: "Otra", LastName: "Ana", Email: " ANA@example.com ", Phone: "+541145550000"},
+	}
+
+	results, err := s.CreateBatch(ctx, users, true)
+	if err != nil {
+		t.Fatalf("CreateBatch atomic: %v", err)
+	}
+	if !errors.Is(results[0].Err, user.ErrBatchAborted) {
+		t.Fatalf("atomic result 0: expected ErrBatchAborted, got %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, user.ErrValidationFailed) {
+		t.Fatalf("atomic result 1: expected ErrValidationFailed, got %v", results[1].Err)
+	}
+	if !errors.Is(results[2].Err, user.ErrUserAlreadyExists) {
+		t.Fatalf("atomic result 2: expected ErrUserAlreadyExists, got %v", results[2].Err)
+	}
+	if count, _ := s.Count(ctx, user.Filters{}); count != 0 {
+		t.Fatalf("Count after aborted batch = %d, want 0", count)
+	}
+
+	results, err = s.CreateBatch(ctx, users, false)
+	if err != nil {
+		t.Fatalf("CreateBatch best-effort: %v", err)
+	}
+	if results[0].Err != nil || results[0].User == nil {
+		t.Fatalf("best-effort result 0: %+v", results[0])
+	}
+	if results[0].User.Phone != "+541145551234" {
+		t.Fatalf("best-effort result 0 stored phone %q, want +541145551234", results[0].User.Phone)
+	}
+	if results[1].Err == nil || results[2].Err == nil {
+		t.Fatalf("best-effort results 1 and 2: expected errors, got %+v", results[1:])
+	}
+
+	if _, err := s.CreateBatch(ctx, nil, false); !errors.Is(err, user.ErrBatchEmpty) {
+		t.Fatalf("empty batch: expected ErrBatchEmpty, got %v", err)
+	}
+	if _, err := s.CreateBatch(ctx, make([]domain.User, user.MaxBatchSize+1), false); !errors.Is(err, user.ErrBatchTooLarge) {
+		t.Fatalf("oversized batch: expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestServiceReplace(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService()
+
+	// 🎯 Upsert: el ID no existe, se crea con ese ID
+	const id = "0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"
+	u, created, err := s.Replace(ctx, id, nil, "Ana", "Gomez", "ana@example.com", "(011) 4555-1234")
+	if err != nil {
+		t.Fatalf("Replace (create): %v", err)
+	}
+	if !created || u.ID != id || u.Phone != "+541145551234" {
+		t.Fatalf("Replace (create) = %+v, created %t", u, created)
+	}
+
+	u, created, err = s.Replace(ctx, id, nil, "Ana María", "Gomez", "ana.maria@example.com", "+541145559876")
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if created || u.FirstName != "Ana María" || u.Email != "ana.maria@example.com" {
+		t.Fatalf("Replace = %+v, created %t", u, created)
+	}
+
+	// Todos los campos son obligatorios
+	if _, _, err := s.Replace(ctx, id, nil, "Ana", "", "ana@example.com", ""); !errors.Is(err, user.ErrValidationFailed) {
+		t.Fatalf("Replace with missing fields: expected ErrValidationFailed, got %v", err)
+	}
+
+	// Un ID inexistente que no es un UUID no se puede crear
+	if _, _, err := s.Replace(ctx, "42", nil, "Bruno", "Diaz", "bruno@example.com", "+541145550000"); !errors.Is(err, user.ErrValidationFailed) {
+		t.Fatalf("Replace with invalid ID: expected ErrValidationFailed, got %v", err)
+	}
+	if count, _ := s.Count(ctx, user.Filters{}); count != 1 {
+		t.Fatalf("Count = %d, want 1", count)
+	}
+}
@@ -0,0 +1,79 @@
+package user
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortField es un criterio de orden de GET /users (ej: "-created_at" => created_at desc)
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// sortableColumns es la lista de campos (nombre JSON) por los que se permite ordenar y su columna
+var sortableColumns = map[string]string{
+	"first_name": "first_name",
+	"last_name":  "last_name",
+	"email":      "email",
+	"phone":      "phone",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// textSortFields son los campos de texto: se ordenan por su valor en minúsculas.
+// 💡 La collation por defecto de MySQL ignora mayúsculas pero SQLite y el repositorio en memoria
+// comparan bytes: en minúsculas "anabel" queda antes que "Bruno" en todos los motores.
+var textSortFields = map[string]bool{
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+	"phone":      true,
+}
+
+// SortFields devuelve, ordenados, los campos por los que se puede ordenar
+func SortFields() []string {
+	fields := make([]string, 0, len(sortableColumns))
+	for field := range sortableColumns {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ParseSort interpreta el parámetro sort: campos separados por coma, con "-" para orden descendente.
+// Solo valida la sintaxis; los campos permitidos los controla el repositorio.
+func ParseSort(value string) ([]SortField, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	fields := make([]SortField, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+
+		field := SortField{Field: part}
+		switch {
+		case strings.HasPrefix(part, "-"):
+			field = SortField{Field: part[1:], Desc: true}
+		case strings.HasPrefix(part, "+"):
+			field = SortField{Field: part[1:]}
+		}
+
+		if field.Field == "" {
+			return nil, NewErrInvalidSort(part)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// sortColumn devuelve la columna de un campo ordenable o ErrInvalidSort si no está permitido
+func sortColumn(field string) (string, error) {
+	column, ok := sortableColumns[field]
+	if !ok {
+		return "", NewErrInvalidSort(field)
+	}
+	return column, nil
+}
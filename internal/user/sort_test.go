@@ -0,0 +1,35 @@
+package user
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []SortField
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "last_name", want: []SortField{{Field: "last_name"}}},
+		{in: "last_name,-created_at", want: []SortField{{Field: "last_name"}, {Field: "created_at", Desc: true}}},
+		{in: " +email , -phone ", want: []SortField{{Field: "email"}, {Field: "phone", Desc: true}}},
+		{in: "last_name,", wantErr: true},
+		{in: "-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSort(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidSortField) {
+				t.Errorf("ParseSort(%q): expected ErrInvalidSortField, got %v", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSort(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package usertest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// StartPostgres levanta un Postgres local como proceso (sin Docker) y devuelve su DSN.
+// Si POSTGRES_TEST_DSN está definido se usa ese servidor directamente.
+// Los binarios (initdb, postgres) se buscan en POSTGRES_BIN o en el PATH;
+// si no están disponibles el test se saltea.
+func StartPostgres(t testing.TB) string {
+	t.Helper()
+
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		return dsn
+	}
+
+	initdb, err := lookPostgresBin("initdb")
+	if err != nil {
+		t.Skip("postgres binaries not found: set POSTGRES_BIN or POSTGRES_TEST_DSN")
+	}
+	server, err := lookPostgresBin("postgres")
+	if err != nil {
+		t.Skip("postgres binaries not found: set POSTGRES_BIN or POSTGRES_TEST_DSN")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres refuses to run as root: set POSTGRES_TEST_DSN")
+	}
+
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust",
+		"--encoding=UTF8", "--locale=C.UTF-8", "--no-sync").CombinedOutput()
+	if err != nil {
+		t.Fatalf("initdb: %v\n%s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("finding free port: %v", err)
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		t.Fatalf("creating postgres log: %v", err)
+	}
+
+	cmd := exec.Command(server, "-D", data, "-p", fmt.Sprint(port), "-k", dir,
+		"-c", "listen_addresses=127.0.0.1", "-c", "fsync=off")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting postgres: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// SIGINT = fast shutdown
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+		_ = logFile.Close()
+	})
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	if err := waitForPort(addr, 15*time.Second); err != nil {
+		log, _ := os.ReadFile(logFile.Name())
+		t.Fatalf("postgres did not start: %v\n%s", err, log)
+	}
+
+	return fmt.Sprintf("host=127.0.0.1 port=%d user=postgres dbname=postgres sslmode=disable", port)
+}
+
+func lookPostgresBin(name string) (string, error) {
+	if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	return exec.LookPath(name)
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func waitForPort(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
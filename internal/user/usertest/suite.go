@@ -0,0 +1,1356 @@
+// Package usertest contiene una suite de conformidad para implementaciones de user.Repository.
+// Cualquier backend nuevo debe pasarla para garantizar que se comporta igual que el repositorio GORM.
+package usertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+)
+
+// Factory devuelve un repositorio vacío y listo para usar en cada subtest
+type Factory func(t *testing.T) user.Repository
+
+// base es la fecha de referencia para los created_at de los datos de prueba.
+// Se trunca a segundos para que todos los motores la almacenen sin pérdida.
+var base = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// RunRepositoryTests ejecuta la suite completa contra el repositorio que devuelve newRepo
+func RunRepositoryTests(t *testing.T, newRepo Factory) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo user.Repository)
+	}{
+		{"Create", testCreate},
+		{"CreateBatch", testCreateBatch},
+		{"CreateBatchAtomic", testCreateBatchAtomic},
+		{"Get", testGet},
+		{"GetByEmail", testGetByEmail},
+		{"GetAll", testGetAll},
+		{"Filters", testFilters},
+		{"Conditions", testConditions},
+		{"LiteralWildcards", testLiteralWildcards},
+		{"DateRanges", testDateRanges},
+		{"Search", testSearch},
+		{"Pagination", testPagination},
+		{"Sort", testSort},
+		{"CursorPagination", testCursorPagination},
+		{"CursorTies", testCursorTies},
+		{"Update", testUpdate},
+		{"UpdateMany", testUpdateMany},
+		{"OptimisticConcurrency", testOptimisticConcurrency},
+		{"VersionAlwaysAdvances", testVersionAlwaysAdvances},
+		{"Delete", testDelete},
+		{"Trash", testTrash},
+		{"Purge", testPurge},
+		{"DeleteMany", testDeleteMany},
+		{"Count", testCount},
+		{"Stats", testStats},
+		{"EmailUniqueness", testEmailUniqueness},
+		{"ConcurrentCreateSameEmail", testConcurrentCreateSameEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+// seed es el conjunto de usuarios base. El orden esperado por created_at desc es el inverso.
+func seed(t *testing.T, repo user.Repository) []domain.User {
+	t.Helper()
+
+	users := []domain.User{
+		{FirstName: "Ana", LastName: "Gomez", Email: "ana.gomez@example.com", Phone: "+541145551234"},
+		{FirstName: "Bruno", LastName: "Diaz", Email: "bruno@example.org", Phone: "+541145559876"},
+		{FirstName: "Carla", LastName: "Gomez", Email: "carla@mail.com", Phone: "+14155550100"},
+		{FirstName: "Diego", LastName: "ÁLVAREZ", Email: "DIEGO@Example.com", Phone: "+34911234567"},
+		{FirstName: "anabel", LastName: "Perez", Email: "anabel@example.com", Phone: "+541145550000"},
+	}
+
+	for i := range users {
+		users[i].CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		users[i].UpdatedAt = users[i].CreatedAt
+		if err := repo.Create(context.Background(), &users[i]); err != nil {
+			t.Fatalf("seed: create %s: %v", users[i].FirstName, err)
+		}
+	}
+	return users
+}
+
+func testCreate(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	u := domain.User{FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234"}
+	if err := repo.Create(ctx, &u); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if u.ID == "" {
+		t.Fatal("Create: expected an ID to be generated")
+	}
+	if u.CreatedAt.IsZero() || u.UpdatedAt.IsZero() {
+		t.Fatal("Create: expected timestamps to be set")
+	}
+
+	got, err := repo.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("Get after Create: %v", err)
+	}
+	assertSameUser(t, u, *got)
+
+	// 🎯 Un ID explícito se respeta y no puede repetirse
+	fixed := domain.User{ID: "00000000-0000-0000-0000-000000000001", FirstName: "Bruno", LastName: "Diaz", Email: "bruno@example.com", Phone: "+541145559876"}
+	if err := repo.Create(ctx, &fixed); err != nil {
+		t.Fatalf("Create with ID: %v", err)
+	}
+	if fixed.ID != "00000000-0000-0000-0000-000000000001" {
+		t.Fatalf("Create with ID: ID changed to %q", fixed.ID)
+	}
+
+	dup := domain.User{ID: fixed.ID, FirstName: "Carla", LastName: "Gomez", Email: "carla@example.com", Phone: "+14155550100"}
+	assertAlreadyExists(t, repo.Create(ctx, &dup), "id")
+
+	// Un usuario borrado lógicamente sigue reservando su ID
+	if err := repo.Delete(ctx, fixed.ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	assertAlreadyExists(t, repo.Create(ctx, &dup), "id")
+}
+
+func testCreateBatch(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	// Best-effort: se crean los válidos aunque otro del lote choque con un email existente
+	batch := []*domain.User{
+		{FirstName: "Elena", LastName: "Ruiz", Email: "elena@example.com", Phone: "+541145551000"},
+		{FirstName: "Otra", LastName: "Ana", Email: "ANA.GOMEZ@example.com", Phone: "+541145551001"},
+		{FirstName: "Fede", LastName: "Sosa", Email: "fede@example.com", Phone: "+541145551002"},
+	}
+	errs := repo.CreateBatch(ctx, batch, false)
+	if len(errs) != len(batch) {
+		t.Fatalf("CreateBatch returned %d errors, want %d", len(errs), len(batch))
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Fatalf("CreateBatch: unexpected errors %v", errs)
+	}
+	assertAlreadyExists(t, errs[1], "email")
+
+	for _, i := range []int{0, 2} {
+		got, err := repo.Get(ctx, batch[i].ID)
+		if err != nil {
+			t.Fatalf("Get %s after CreateBatch: %v", batch[i].FirstName, err)
+		}
+		assertSameUser(t, *batch[i], *got)
+	}
+
+	count, err := repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if want := int64(len(users) + 2); count != want {
+		t.Fatalf("Count after CreateBatch = %d, want %d", count, want)
+	}
+}
+
+func testCreateBatchAtomic(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	// 🎯 Atomic: un email repetido deshace todo el lote
+	batch := []*domain.User{
+		{FirstName: "Elena", LastName: "Ruiz", Email: "elena@example.com", Phone: "+541145551000"},
+		{FirstName: "Otro", LastName: "Bruno", Email: users[1].Email, Phone: "+541145551001"},
+		{FirstName: "Fede", LastName: "Sosa", Email: "fede@example.com", Phone: "+541145551002"},
+	}
+	errs := repo.CreateBatch(ctx, batch, true)
+	if len(errs) != len(batch) {
+		t.Fatalf("CreateBatch returned %d errors, want %d", len(errs), len(batch))
+	}
+	assertAlreadyExists(t, errs[1], "email")
+	for _, i := range []int{0, 2} {
+		if !errors.Is(errs[i], user.ErrBatchAborted) {
+			t.Fatalf("CreateBatch[%d]: expected ErrBatchAborted, got %v", i, errs[i])
+		}
+	}
+
+	count, err := repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != int64(len(users)) {
+		t.Fatalf("Count after aborted CreateBatch = %d, want %d", count, len(users))
+	}
+	if _, err := repo.GetByEmail(ctx, "elena@example.com"); err == nil {
+		t.Fatal("GetByEmail: user of an aborted batch was created")
+	}
+
+	// Sin conflictos se crean todos
+	batch = []*domain.User{
+		{FirstName: "Elena", LastName: "Ruiz", Email: "elena@example.com", Phone: "+541145551000"},
+		{FirstName: "Fede", LastName: "Sosa", Email: "fede@example.com", Phone: "+541145551002"},
+	}
+	for i, err := range repo.CreateBatch(ctx, batch, true) {
+		if err != nil {
+			t.Fatalf("CreateBatch[%d]: %v", i, err)
+		}
+		if batch[i].ID == "" {
+			t.Fatalf("CreateBatch[%d]: expected an ID to be generated", i)
+		}
+	}
+
+	count, err = repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if want := int64(len(users) + 2); count != want {
+		t.Fatalf("Count after CreateBatch = %d, want %d", count, want)
+	}
+}
+
+func testGet(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	for _, u := range users {
+		got, err := repo.Get(ctx, u.ID)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", u.ID, err)
+		}
+		assertSameUser(t, u, *got)
+	}
+
+	_, err := repo.Get(ctx, "00000000-0000-0000-0000-00000000dead")
+	assertNotFound(t, err, "00000000-0000-0000-0000-00000000dead")
+}
+
+func testGetByEmail(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	// 🎯 Coincidencia exacta sin distinguir mayúsculas
+	for _, email := range []string{"diego@example.com", "DIEGO@Example.com", "Diego@EXAMPLE.COM"} {
+		got, err := repo.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatalf("GetByEmail(%q): %v", email, err)
+		}
+		assertSameUser(t, users[3], *got)
+	}
+
+	// Un substring no es una coincidencia: "ana@example.com" no debe devolver "anabel@example.com"
+	for _, email := range []string{"ana@example.com", "example.com", "anabel@example.com.ar"} {
+		_, err := repo.GetByEmail(ctx, email)
+		assertEmailNotFound(t, err, email)
+	}
+
+	if err := repo.Delete(ctx, users[3].ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err := repo.GetByEmail(ctx, "diego@example.com")
+	assertEmailNotFound(t, err, "diego@example.com")
+}
+
+func testGetAll(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	got, err := repo.GetAll(ctx, user.Filters{}, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+
+	// created_at desc: el último creado va primero
+	want := make([]string, 0, len(users))
+	for i := len(users) - 1; i >= 0; i-- {
+		want = append(want, users[i].ID)
+	}
+	assertIDs(t, got, want...)
+}
+
+func testFilters(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		{"no filters", user.Filters{}, []string{anabel, diego, carla, bruno, ana}},
+		{"first name substring", user.Filters{FirstName: "ana"}, []string{anabel, ana}},
+		{"first name case insensitive", user.Filters{FirstName: "ANA"}, []string{anabel, ana}},
+		{"last name", user.Filters{LastName: "gomez"}, []string{carla, ana}},
+		{"last name non ascii", user.Filters{LastName: "álvarez"}, []string{diego}},
+		{"email", user.Filters{Email: "example.com"}, []string{anabel, diego, ana}},
+		{"email case insensitive", user.Filters{Email: "diego@example"}, []string{diego}},
+		{"phone", user.Filters{Phone: "+54114555"}, []string{anabel, bruno, ana}},
+		{"first name and last name", user.Filters{FirstName: "a", LastName: "gomez"}, []string{carla, ana}},
+		{"all fields", user.Filters{FirstName: "an", LastName: "go", Email: "ana", Phone: "1234"}, []string{ana}},
+		{"no match", user.Filters{FirstName: "ana", LastName: "diaz"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+
+			count, err := repo.Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Fatalf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func testConditions(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	// Un usuario cuyo email extiende al de otro: eq no debe confundirlos
+	ar := domain.User{FirstName: "Ana", LastName: "Ar", Email: "ana.gomez@example.com.ar", Phone: "+541145557777", CreatedAt: base.Add(time.Hour)}
+	if err := repo.Create(ctx, &ar); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	cond := func(field string, op user.FilterOp, values ...string) user.Condition {
+		return user.Condition{Field: field, Op: op, Values: values}
+	}
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		{"eq", user.Filters{Conditions: []user.Condition{cond("email", user.OpEq, "ana.gomez@example.com")}}, []string{ana}},
+		{"eq case insensitive", user.Filters{Conditions: []user.Condition{cond("email", user.OpEq, "Diego@example.COM")}}, []string{diego}},
+		{"eq no partial match", user.Filters{Conditions: []user.Condition{cond("first_name", user.OpEq, "an")}}, nil},
+		{"prefix", user.Filters{Conditions: []user.Condition{cond("first_name", user.OpPrefix, "ana")}}, []string{ar.ID, anabel, ana}},
+		{"prefix is anchored", user.Filters{Conditions: []user.Condition{cond("last_name", user.OpPrefix, "omez")}}, nil},
+		{"contains", user.Filters{Conditions: []user.Condition{cond("last_name", user.OpContains, "OME")}}, []string{carla, ana}},
+		{"in", user.Filters{Conditions: []user.Condition{cond("phone", user.OpIn, "+541145559876", "+14155550100", "+10000000000")}}, []string{carla, bruno}},
+		{"combined with AND", user.Filters{
+			Conditions: []user.Condition{cond("first_name", user.OpPrefix, "ana"), cond("email", user.OpIn, "anabel@example.com", "ana.gomez@example.com")},
+		}, []string{anabel, ana}},
+		{"combined with simple filters", user.Filters{
+			LastName:   "ar",
+			Conditions: []user.Condition{cond("first_name", user.OpEq, "ana")},
+		}, []string{ar.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+
+			count, err := repo.Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Fatalf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+
+	// Campos fuera de la lista permitida
+	invalid := user.Filters{Conditions: []user.Condition{cond("id; DROP TABLE users", user.OpEq, "x")}}
+	if _, err := repo.GetAll(ctx, invalid, 0, 100); !errors.Is(err, user.ErrInvalidFilterParam) {
+		t.Fatalf("GetAll with invalid field: expected ErrInvalidFilterParam, got %v", err)
+	}
+	if _, err := repo.Count(ctx, invalid); !errors.Is(err, user.ErrInvalidFilterParam) {
+		t.Fatalf("Count with invalid field: expected ErrInvalidFilterParam, got %v", err)
+	}
+}
+
+// testLiteralWildcards verifica que %, _ y \ se comparan literalmente y no como comodines de LIKE
+func testLiteralWildcards(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	seed(t, repo)
+
+	literal := domain.User{FirstName: "Ana", LastName: `100%_Back\slash`, Email: "ana_b@example.com", Phone: "+541145558888", CreatedAt: base.Add(time.Hour)}
+	if err := repo.Create(ctx, &literal); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	cond := func(field string, op user.FilterOp, value string) []user.Condition {
+		return []user.Condition{{Field: field, Op: op, Values: []string{value}}}
+	}
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		// Como comodín, "ana_" también encontraría a anabel@example.com
+		{"prefix with underscore", user.Filters{Conditions: cond("email", user.OpPrefix, "ana_")}, []string{literal.ID}},
+		{"contains with underscore", user.Filters{Conditions: cond("email", user.OpContains, "a_b")}, []string{literal.ID}},
+		{"contains with percent", user.Filters{Conditions: cond("last_name", user.OpContains, "0%_b")}, []string{literal.ID}},
+		{"contains with backslash", user.Filters{Conditions: cond("last_name", user.OpContains, `k\s`)}, []string{literal.ID}},
+		{"filter with underscore", user.Filters{Email: "_"}, []string{literal.ID}},
+		{"filter with percent", user.Filters{LastName: "%"}, []string{literal.ID}},
+		{"search with underscore", user.Filters{Query: "ana_"}, []string{literal.ID}},
+		{"no match", user.Filters{Conditions: cond("first_name", user.OpPrefix, "_")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+
+			count, err := repo.Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Fatalf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func testDateRanges(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	// Solo ana se actualiza: su updated_at pasa a ser "ahora"
+	name := "Ana María"
+	if _, err := repo.Update(ctx, ana, nil, &name, nil, nil, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	at := func(minutes int) *time.Time {
+		t := base.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+	// Las fechas pueden venir en cualquier zona horaria
+	inBuenosAires := base.Add(90 * time.Second).In(time.FixedZone("ART", -3*60*60))
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		{"created after is exclusive", user.Filters{CreatedAfter: at(2)}, []string{anabel, diego}},
+		{"created before is exclusive", user.Filters{CreatedBefore: at(2)}, []string{bruno, ana}},
+		{"created between", user.Filters{CreatedAfter: at(0), CreatedBefore: at(4)}, []string{diego, carla, bruno}},
+		{"other time zone", user.Filters{CreatedAfter: &inBuenosAires}, []string{anabel, diego, carla}},
+		{"updated after", user.Filters{UpdatedAfter: at(60)}, []string{ana}},
+		{"updated before", user.Filters{UpdatedBefore: at(60)}, []string{anabel, diego, carla, bruno}},
+		{"combined with filters", user.Filters{LastName: "gomez", CreatedBefore: at(3)}, []string{carla, ana}},
+		{"empty range", user.Filters{CreatedAfter: at(3), CreatedBefore: at(3)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+
+			count, err := repo.Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Fatalf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func testSearch(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, carla, diego, anabel := users[0].ID, users[2].ID, users[3].ID, users[4].ID
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		{"every term must match", user.Filters{Query: "ana gomez"}, []string{ana}},
+		{"exact match ranks above prefix", user.Filters{Query: "ana"}, []string{ana, anabel}},
+		{"ties keep created_at desc", user.Filters{Query: "Gomez"}, []string{carla, ana}},
+		{"email and name terms", user.Filters{Query: "example.com ana"}, []string{ana, anabel}},
+		{"non ascii", user.Filters{Query: "álvarez"}, []string{diego}},
+		{"phone written differently", user.Filters{Query: "4555-1234"}, []string{ana}},
+		{"phone with country code", user.Filters{Query: "+1(415)555.0100"}, []string{carla}},
+		// Los dígitos de un email o un nombre no se comparan con los teléfonos
+		{"email term with digits", user.Filters{Query: "ana.gomez2@example.com"}, nil},
+		{"name term with digits", user.Filters{Query: "bruno9"}, nil},
+		{"too few digits for a phone", user.Filters{Query: "55"}, nil},
+		{"extra spaces", user.Filters{Query: "  ana   gomez "}, []string{ana}},
+		{"no match", user.Filters{Query: "ana diaz"}, nil},
+		{"combined with filters", user.Filters{Query: "gomez", FirstName: "carla"}, []string{carla}},
+		{"explicit sort wins over relevance", user.Filters{Query: "ana", Sort: []user.SortField{{Field: "created_at", Desc: true}}}, []string{anabel, ana}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+
+			count, err := repo.Count(ctx, tt.filters)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Fatalf("Count = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+}
+
+func testPagination(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []string
+	}{
+		{"first page", 0, 2, []string{anabel, diego}},
+		{"second page", 2, 2, []string{carla, bruno}},
+		{"last partial page", 4, 2, []string{ana}},
+		{"offset past the end", 10, 2, nil},
+		{"limit larger than total", 0, 50, []string{anabel, diego, carla, bruno, ana}},
+		{"zero limit", 0, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, user.Filters{}, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+		})
+	}
+
+	// 🔧 La paginación se aplica después de los filtros
+	got, err := repo.GetAll(ctx, user.Filters{Phone: "+54"}, 1, 1)
+	if err != nil {
+		t.Fatalf("GetAll filtered: %v", err)
+	}
+	assertIDs(t, got, bruno)
+}
+
+func testSort(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	tests := []struct {
+		name    string
+		filters user.Filters
+		want    []string
+	}{
+		{"last name asc", user.Filters{Phone: "+54", Sort: []user.SortField{{Field: "last_name"}}}, []string{bruno, ana, anabel}},
+		{"last name desc", user.Filters{Phone: "+54", Sort: []user.SortField{{Field: "last_name", Desc: true}}}, []string{anabel, ana, bruno}},
+		{"tie broken by created_at desc", user.Filters{LastName: "gomez", Sort: []user.SortField{{Field: "last_name"}, {Field: "created_at", Desc: true}}}, []string{carla, ana}},
+		{"tie broken by created_at asc", user.Filters{LastName: "gomez", Sort: []user.SortField{{Field: "last_name"}, {Field: "created_at"}}}, []string{ana, carla}},
+		{"phone asc", user.Filters{Phone: "+54", Sort: []user.SortField{{Field: "phone"}}}, []string{anabel, ana, bruno}},
+		// 🎯 El texto se ordena sin distinguir mayúsculas en todos los motores, sin importar la collation
+		{"mixed case asc", user.Filters{Sort: []user.SortField{{Field: "first_name"}}}, []string{ana, anabel, bruno, carla, diego}},
+		{"mixed case desc", user.Filters{Sort: []user.SortField{{Field: "first_name", Desc: true}}}, []string{diego, carla, bruno, anabel, ana}},
+		{"mixed case email", user.Filters{Sort: []user.SortField{{Field: "email"}}}, []string{ana, anabel, bruno, carla, diego}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(ctx, tt.filters, 0, 100)
+			if err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			assertIDs(t, got, tt.want...)
+		})
+	}
+
+	// 🔧 La paginación respeta el orden pedido
+	got, err := repo.GetAll(ctx, user.Filters{Phone: "+54", Sort: []user.SortField{{Field: "last_name"}}}, 1, 1)
+	if err != nil {
+		t.Fatalf("GetAll paginated: %v", err)
+	}
+	assertIDs(t, got, ana)
+
+	// Campos fuera de la lista permitida
+	for _, field := range []string{"password", "deleted", "first_name; DROP TABLE users"} {
+		_, err := repo.GetAll(ctx, user.Filters{Sort: []user.SortField{{Field: field}}}, 0, 100)
+		if !errors.Is(err, user.ErrInvalidSortField) {
+			t.Fatalf("sort by %q: expected ErrInvalidSortField, got %v", field, err)
+		}
+	}
+}
+
+func testCursorPagination(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	ana, bruno, carla, diego, anabel := users[0].ID, users[1].ID, users[2].ID, users[3].ID, users[4].ID
+
+	page, err := repo.GetAllByCursor(ctx, user.Filters{}, nil, 2)
+	if err != nil {
+		t.Fatalf("GetAllByCursor first page: %v", err)
+	}
+	assertIDs(t, page, anabel, diego)
+
+	// 🎯 Un alta en medio del recorrido no corre las páginas siguientes
+	newest := domain.User{FirstName: "Eva", LastName: "Nueva", Email: "eva@example.com", Phone: "+541145554444", CreatedAt: base.Add(time.Hour)}
+	if err := repo.Create(ctx, &newest); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	after := user.NewCursor(page[len(page)-1])
+	page, err = repo.GetAllByCursor(ctx, user.Filters{}, &after, 2)
+	if err != nil {
+		t.Fatalf("GetAllByCursor second page: %v", err)
+	}
+	assertIDs(t, page, carla, bruno)
+
+	after = user.NewCursor(page[len(page)-1])
+	page, err = repo.GetAllByCursor(ctx, user.Filters{}, &after, 2)
+	if err != nil {
+		t.Fatalf("GetAllByCursor last page: %v", err)
+	}
+	assertIDs(t, page, ana)
+
+	after = user.NewCursor(page[0])
+	page, err = repo.GetAllByCursor(ctx, user.Filters{}, &after, 2)
+	if err != nil {
+		t.Fatalf("GetAllByCursor past the end: %v", err)
+	}
+	assertIDs(t, page)
+
+	// Los filtros se aplican igual que en GetAll
+	page, err = repo.GetAllByCursor(ctx, user.Filters{LastName: "gomez"}, nil, 10)
+	if err != nil {
+		t.Fatalf("GetAllByCursor filtered: %v", err)
+	}
+	assertIDs(t, page, carla, ana)
+}
+
+func testCursorTies(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	// Usuarios con el mismo created_at: el id desempata y ninguno se repite ni se pierde
+	ids := []string{
+		"00000000-0000-0000-0000-00000000000a",
+		"00000000-0000-0000-0000-00000000000c",
+		"00000000-0000-0000-0000-00000000000b",
+	}
+	for i, id := range ids {
+		u := domain.User{ID: id, FirstName: "Tie", LastName: fmt.Sprint(i), Email: fmt.Sprintf("tie%d@example.com", i), Phone: "+541145550000", CreatedAt: base}
+		if err := repo.Create(ctx, &u); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	var seen []domain.User
+	var after *user.Cursor
+	for i := 0; i < len(ids)+1; i++ {
+		page, err := repo.GetAllByCursor(ctx, user.Filters{}, after, 1)
+		if err != nil {
+			t.Fatalf("GetAllByCursor: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		seen = append(seen, page...)
+		c := user.NewCursor(page[0])
+		after = &c
+	}
+	assertIDs(t, seen, ids[1], ids[2], ids[0])
+}
+
+func testUpdate(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	target := users[1]
+
+	// Solo cambian los campos no nil
+	newName := "Bruce"
+	newPhone := "+541145550001"
+	got, err := repo.Update(ctx, target.ID, nil, &newName, nil, nil, &newPhone)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	want := target
+	want.FirstName = newName
+	want.Phone = newPhone
+	assertSameUser(t, want, *got)
+	if got.UpdatedAt.Before(target.UpdatedAt) || got.UpdatedAt.Equal(target.UpdatedAt) {
+		t.Fatalf("Update: updated_at not refreshed (%v -> %v)", target.UpdatedAt, got.UpdatedAt)
+	}
+
+	stored, err := repo.Get(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("Get after Update: %v", err)
+	}
+	assertSameUser(t, want, *stored)
+
+	// El resto de usuarios no se ve afectado
+	other, err := repo.Get(ctx, users[0].ID)
+	if err != nil {
+		t.Fatalf("Get other: %v", err)
+	}
+	assertSameUser(t, users[0], *other)
+
+	email := "nobody@example.com"
+	_, err = repo.Update(ctx, "00000000-0000-0000-0000-00000000dead", nil, nil, nil, &email, nil)
+	assertNotFound(t, err, "00000000-0000-0000-0000-00000000dead")
+
+	// Un usuario borrado no puede actualizarse
+	if err := repo.Delete(ctx, users[2].ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err = repo.Update(ctx, users[2].ID, nil, nil, nil, &email, nil)
+	assertNotFound(t, err, users[2].ID)
+}
+
+func testOptimisticConcurrency(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	target := users[0]
+
+	stored, err := repo.Get(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	version := user.VersionOf(*stored)
+
+	// 🎯 Con la versión vigente el update se aplica y la versión avanza
+	name := "Ana María"
+	updated, err := repo.Update(ctx, target.ID, &version, &name, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Update with current version: %v", err)
+	}
+	if updated.FirstName != name {
+		t.Fatalf("Update: first_name = %q, want %q", updated.FirstName, name)
+	}
+	next := user.VersionOf(*updated)
+	if next == version {
+		t.Fatal("Update: version did not change")
+	}
+
+	// Dos escrituras seguidas siempre cambian la versión, aunque caigan en el mismo milisegundo
+	again, err := repo.Update(ctx, target.ID, &next, &name, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("second Update: %v", err)
+	}
+	if user.VersionOf(*again) == next {
+		t.Fatal("second Update: version did not change")
+	}
+
+	// La versión vieja ya no sirve ni para actualizar ni para borrar
+	other := "Otra"
+	if _, err := repo.Update(ctx, target.ID, &version, &other, nil, nil, nil); !errors.Is(err, user.ErrVersionMismatch) {
+		t.Fatalf("Update with stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	if err := repo.Delete(ctx, target.ID, &next); !errors.Is(err, user.ErrVersionMismatch) {
+		t.Fatalf("Delete with stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	got, err := repo.Get(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.FirstName != name {
+		t.Fatalf("stale Update was applied: first_name = %q", got.FirstName)
+	}
+
+	current := user.VersionOf(*got)
+	if err := repo.Delete(ctx, target.ID, &current); err != nil {
+		t.Fatalf("Delete with current version: %v", err)
+	}
+
+	// Sobre un usuario inexistente sigue siendo not found
+	_, err = repo.Update(ctx, target.ID, &current, &other, nil, nil, nil)
+	assertNotFound(t, err, target.ID)
+	assertNotFound(t, repo.Delete(ctx, target.ID, &current), target.ID)
+}
+
+// testVersionAlwaysAdvances verifica que toda escritura, también sin If-Match, deja una versión
+// posterior: quien tenga el ETag anterior no puede escribir encima
+func testVersionAlwaysAdvances(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	// 💡 Con updated_at en el futuro (ej: relojes desfasados) "ahora" no alcanza para que la versión
+	// avance; así el test no depende de que dos escrituras caigan en el mismo milisegundo
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	target := domain.User{FirstName: "Ana", LastName: "Gomez", Email: "ana@example.com", Phone: "+541145551234", CreatedAt: base, UpdatedAt: future}
+	if err := repo.Create(ctx, &target); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	writes := []struct {
+		name  string
+		write func(i int) error
+	}{
+		{"update", func(i int) error {
+			name := fmt.Sprint("Ana ", i)
+			_, err := repo.Update(ctx, target.ID, nil, &name, nil, nil, nil)
+			return err
+		}},
+		{"update many", func(i int) error {
+			lastName := fmt.Sprint("Gomez ", i)
+			_, err := repo.UpdateMany(ctx, user.Filters{IDs: []string{target.ID}}, nil, &lastName, nil, false)
+			return err
+		}},
+		{"restore", func(int) error {
+			if err := repo.Delete(ctx, target.ID, nil); err != nil {
+				return err
+			}
+			_, err := repo.Restore(ctx, target.ID)
+			return err
+		}},
+	}
+
+	for i, w := range writes {
+		before, err := repo.Get(ctx, target.ID)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", w.name, err)
+		}
+		stale := user.VersionOf(*before)
+
+		if err := w.write(i); err != nil {
+			t.Fatalf("%s: %v", w.name, err)
+		}
+		after, err := repo.Get(ctx, target.ID)
+		if err != nil {
+			t.Fatalf("%s: Get: %v", w.name, err)
+		}
+		if version := user.VersionOf(*after); version <= stale {
+			t.Fatalf("%s: version went from %d to %d, want it to advance", w.name, stale, version)
+		}
+
+		// 🎯 Con el ETag anterior la escritura se rechaza
+		other := "Stale"
+		if _, err := repo.Update(ctx, target.ID, &stale, &other, nil, nil, nil); !errors.Is(err, user.ErrVersionMismatch) {
+			t.Fatalf("%s: Update with the previous version: expected ErrVersionMismatch, got %v", w.name, err)
+		}
+	}
+}
+
+func testDelete(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	target := users[0]
+
+	if err := repo.Delete(ctx, target.ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	_, err := repo.Get(ctx, target.ID)
+	assertNotFound(t, err, target.ID)
+
+	got, err := repo.GetAll(ctx, user.Filters{}, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, got, users[4].ID, users[3].ID, users[2].ID, users[1].ID)
+
+	count, err := repo.Count(ctx, user.Filters{FirstName: "ana"})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Count after delete = %d, want 1", count)
+	}
+
+	// Borrar dos veces o un ID inexistente devuelve not found
+	assertNotFound(t, repo.Delete(ctx, target.ID, nil), target.ID)
+	assertNotFound(t, repo.Delete(ctx, "00000000-0000-0000-0000-00000000dead", nil), "00000000-0000-0000-0000-00000000dead")
+}
+
+func testTrash(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	missing := "00000000-0000-0000-0000-00000000dead"
+
+	for _, u := range []domain.User{users[0], users[2]} {
+		if err := repo.Delete(ctx, u.ID, nil); err != nil {
+			t.Fatalf("Delete %s: %v", u.FirstName, err)
+		}
+	}
+
+	// La papelera admite los mismos filtros, orden y paginación que el listado
+	trash := user.Filters{Deleted: true}
+	got, err := repo.GetAll(ctx, trash, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll deleted: %v", err)
+	}
+	assertIDs(t, got, users[2].ID, users[0].ID)
+
+	got, err = repo.GetAll(ctx, user.Filters{Deleted: true, FirstName: "carla"}, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll deleted filtered: %v", err)
+	}
+	assertIDs(t, got, users[2].ID)
+
+	got, err = repo.GetAllByCursor(ctx, trash, nil, 1)
+	if err != nil {
+		t.Fatalf("GetAllByCursor deleted: %v", err)
+	}
+	assertIDs(t, got, users[2].ID)
+
+	count, err := repo.Count(ctx, trash)
+	if err != nil {
+		t.Fatalf("Count deleted: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Count deleted = %d, want 2", count)
+	}
+
+	restored, err := repo.Restore(ctx, users[0].ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.ID != users[0].ID || restored.Email != users[0].Email {
+		t.Fatalf("Restore returned %+v, want %s", *restored, users[0].ID)
+	}
+	if !restored.UpdatedAt.After(users[0].UpdatedAt) {
+		t.Fatalf("Restore kept updated_at %v, want a newer one", restored.UpdatedAt)
+	}
+	if _, err := repo.Get(ctx, users[0].ID); err != nil {
+		t.Fatalf("Get after restore: %v", err)
+	}
+	got, err = repo.GetAll(ctx, trash, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll deleted after restore: %v", err)
+	}
+	assertIDs(t, got, users[2].ID)
+
+	// Solo se restaura lo que está en la papelera
+	if _, err := repo.Restore(ctx, users[0].ID); !errors.Is(err, user.ErrUserNotInTrash) {
+		t.Fatalf("Restore active user: expected ErrUserNotInTrash, got %v", err)
+	}
+	_, err = repo.Restore(ctx, missing)
+	assertNotFound(t, err, missing)
+}
+
+func testPurge(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+	missing := "00000000-0000-0000-0000-00000000dead"
+
+	// Purga tanto usuarios activos como los que están en la papelera
+	if err := repo.Purge(ctx, users[0].ID, nil); err != nil {
+		t.Fatalf("Purge active user: %v", err)
+	}
+	if err := repo.Delete(ctx, users[1].ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Purge(ctx, users[1].ID, nil); err != nil {
+		t.Fatalf("Purge deleted user: %v", err)
+	}
+
+	for _, u := range users[:2] {
+		_, err := repo.Restore(ctx, u.ID)
+		assertNotFound(t, err, u.ID)
+	}
+	count, err := repo.Count(ctx, user.Filters{Deleted: true})
+	if err != nil {
+		t.Fatalf("Count deleted: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Count deleted after purge = %d, want 0", count)
+	}
+	assertNotFound(t, repo.Purge(ctx, users[0].ID, nil), users[0].ID)
+	assertNotFound(t, repo.Purge(ctx, missing, nil), missing)
+
+	// El email y el ID quedan libres
+	again := domain.User{ID: users[0].ID, FirstName: "Ana", LastName: "Gomez", Email: users[0].Email, Phone: users[0].Phone}
+	if err := repo.Create(ctx, &again); err != nil {
+		t.Fatalf("Create with purged ID and email: %v", err)
+	}
+
+	// Con versión, igual que Delete
+	stale := user.VersionOf(users[2]) - 1
+	if err := repo.Purge(ctx, users[2].ID, &stale); !errors.Is(err, user.ErrVersionMismatch) {
+		t.Fatalf("Purge with stale version: expected ErrVersionMismatch, got %v", err)
+	}
+	current := user.VersionOf(users[2])
+	if err := repo.Purge(ctx, users[2].ID, &current); err != nil {
+		t.Fatalf("Purge with current version: %v", err)
+	}
+}
+
+func testUpdateMany(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	// Los Gomez son Ana y Carla; Ana ya tiene ese teléfono y no cuenta como afectada
+	phone := users[0].Phone
+	filters := user.Filters{Conditions: []user.Condition{{Field: "last_name", Op: user.OpEq, Values: []string{"gomez"}}}}
+
+	result, err := repo.UpdateMany(ctx, filters, nil, nil, &phone, true)
+	if err != nil {
+		t.Fatalf("UpdateMany dry run: %v", err)
+	}
+	if result.Matched != 2 || result.Affected != 1 || !result.DryRun {
+		t.Fatalf("UpdateMany dry run = %+v, want 2 matched and 1 affected", *result)
+	}
+	got, err := repo.Get(ctx, users[2].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Phone != users[2].Phone {
+		t.Fatalf("dry run changed phone to %q", got.Phone)
+	}
+
+	result, err = repo.UpdateMany(ctx, filters, nil, nil, &phone, false)
+	if err != nil {
+		t.Fatalf("UpdateMany: %v", err)
+	}
+	if result.Matched != 2 || result.Affected != 1 || result.DryRun {
+		t.Fatalf("UpdateMany = %+v, want 2 matched and 1 affected", *result)
+	}
+	got, err = repo.Get(ctx, users[2].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Phone != phone || !got.UpdatedAt.After(users[2].UpdatedAt) {
+		t.Fatalf("UpdateMany: got phone %q updated_at %v", got.Phone, got.UpdatedAt)
+	}
+	got, err = repo.Get(ctx, users[0].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertSameUser(t, users[0], *got)
+
+	// Por lista de IDs, incluyendo uno inexistente
+	last := "Nuevo"
+	result, err = repo.UpdateMany(ctx, user.Filters{IDs: []string{users[1].ID, users[3].ID, "00000000-0000-0000-0000-00000000dead"}}, nil, &last, nil, false)
+	if err != nil {
+		t.Fatalf("UpdateMany by IDs: %v", err)
+	}
+	if result.Matched != 2 || result.Affected != 2 {
+		t.Fatalf("UpdateMany by IDs = %+v, want 2 matched and 2 affected", *result)
+	}
+	updated, err := repo.GetAll(ctx, user.Filters{Conditions: []user.Condition{{Field: "last_name", Op: user.OpEq, Values: []string{"nuevo"}}}}, 0, 10)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, updated, users[3].ID, users[1].ID)
+}
+
+func testDeleteMany(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	filters := user.Filters{Email: "example.com"}
+	result, err := repo.DeleteMany(ctx, filters, true)
+	if err != nil {
+		t.Fatalf("DeleteMany dry run: %v", err)
+	}
+	if result.Matched != 3 || result.Affected != 3 || !result.DryRun {
+		t.Fatalf("DeleteMany dry run = %+v, want 3 matched and 3 affected", *result)
+	}
+	if count, _ := repo.Count(ctx, user.Filters{}); count != int64(len(users)) {
+		t.Fatalf("Count after dry run = %d, want %d", count, len(users))
+	}
+
+	result, err = repo.DeleteMany(ctx, filters, false)
+	if err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if result.Matched != 3 || result.Affected != 3 {
+		t.Fatalf("DeleteMany = %+v, want 3 matched and 3 affected", *result)
+	}
+	remaining, err := repo.GetAll(ctx, user.Filters{}, 0, 10)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	assertIDs(t, remaining, users[2].ID, users[1].ID)
+	assertNotFound(t, repo.Delete(ctx, users[0].ID, nil), users[0].ID)
+
+	// Los ya borrados no vuelven a contar
+	result, err = repo.DeleteMany(ctx, user.Filters{IDs: []string{users[0].ID, users[1].ID}}, false)
+	if err != nil {
+		t.Fatalf("DeleteMany by IDs: %v", err)
+	}
+	if result.Matched != 1 || result.Affected != 1 {
+		t.Fatalf("DeleteMany by IDs = %+v, want 1 matched and 1 affected", *result)
+	}
+}
+
+func testCount(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	count, err := repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count on empty repository: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Count on empty repository = %d, want 0", count)
+	}
+
+	seed(t, repo)
+
+	count, err = repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 5 {
+		t.Fatalf("Count = %d, want 5", count)
+	}
+
+	// Count ignora la paginación y coincide con GetAll sin límite
+	all, err := repo.GetAll(ctx, user.Filters{Email: "example"}, 0, 100)
+	if err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	count, err = repo.Count(ctx, user.Filters{Email: "example"})
+	if err != nil {
+		t.Fatalf("Count filtered: %v", err)
+	}
+	if count != int64(len(all)) {
+		t.Fatalf("Count = %d, GetAll returned %d", count, len(all))
+	}
+}
+
+func testStats(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	stats, err := repo.Stats(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Stats on empty repository: %v", err)
+	}
+	if stats.Count != 0 || !stats.LastModified.IsZero() {
+		t.Fatalf("Stats on empty repository = %+v, want zero", stats)
+	}
+
+	users := seed(t, repo)
+
+	stats, err = repo.Stats(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if want := users[4].UpdatedAt; stats.Count != 5 || !stats.LastModified.Equal(want) {
+		t.Fatalf("Stats = %+v, want 5 users and %v", stats, want)
+	}
+
+	// Solo cuenta a los usuarios que cumplen los filtros
+	stats, err = repo.Stats(ctx, user.Filters{Email: "example.org"})
+	if err != nil {
+		t.Fatalf("Stats filtered: %v", err)
+	}
+	if want := users[1].UpdatedAt; stats.Count != 1 || !stats.LastModified.Equal(want) {
+		t.Fatalf("Stats filtered = %+v, want 1 user and %v", stats, want)
+	}
+	stats, err = repo.Stats(ctx, user.Filters{Email: "nobody"})
+	if err != nil {
+		t.Fatalf("Stats without matches: %v", err)
+	}
+	if stats.Count != 0 || !stats.LastModified.IsZero() {
+		t.Fatalf("Stats without matches = %+v, want zero", stats)
+	}
+
+	// Una actualización mueve LastModified hacia adelante
+	name := "Anita"
+	updated, err := repo.Update(ctx, users[0].ID, nil, &name, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	stats, err = repo.Stats(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Stats after update: %v", err)
+	}
+	if user.VersionOf(domain.User{UpdatedAt: stats.LastModified}) != user.VersionOf(*updated) {
+		t.Fatalf("Stats after update = %v, want %v", stats.LastModified, updated.UpdatedAt)
+	}
+
+	// Los usuarios en la papelera solo cuentan al listar la papelera
+	if err := repo.Delete(ctx, users[4].ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if stats, err = repo.Stats(ctx, user.Filters{}); err != nil || stats.Count != 4 {
+		t.Fatalf("Stats after delete = %+v, %v, want 4 users", stats, err)
+	}
+	if stats, err = repo.Stats(ctx, user.Filters{Deleted: true}); err != nil || stats.Count != 1 {
+		t.Fatalf("Stats of the trash = %+v, %v, want 1 user", stats, err)
+	}
+
+	// Los filtros inválidos fallan igual que en GetAll
+	invalid := user.Filters{Conditions: []user.Condition{{Field: "password", Op: user.OpEq, Values: []string{"x"}}}}
+	if _, err := repo.Stats(ctx, invalid); !errors.Is(err, user.ErrInvalidFilterParam) {
+		t.Fatalf("Stats with invalid field: expected ErrInvalidFilterParam, got %v", err)
+	}
+}
+
+func testEmailUniqueness(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+	users := seed(t, repo)
+
+	// 🎯 El email se compara sin distinguir mayúsculas
+	dup := domain.User{FirstName: "Otra", LastName: "Ana", Email: "ANA.Gomez@example.com", Phone: "+541145550002"}
+	assertAlreadyExists(t, repo.Create(ctx, &dup), "email")
+
+	count, err := repo.Count(ctx, user.Filters{})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != int64(len(users)) {
+		t.Fatalf("Count after rejected create = %d, want %d", count, len(users))
+	}
+
+	// Update al email de otro usuario
+	taken := "bruno@EXAMPLE.org"
+	_, err = repo.Update(ctx, users[0].ID, nil, nil, nil, &taken, nil)
+	assertAlreadyExists(t, err, "email")
+
+	// Update al propio email con otras mayúsculas está permitido
+	own := "Ana.Gomez@example.com"
+	got, err := repo.Update(ctx, users[0].ID, nil, nil, nil, &own, nil)
+	if err != nil {
+		t.Fatalf("Update own email: %v", err)
+	}
+	if got.Email != own {
+		t.Fatalf("Update own email: got %q, want %q", got.Email, own)
+	}
+
+	// Un usuario borrado lógicamente sigue reservando su email
+	if err := repo.Delete(ctx, users[2].ID, nil); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	reuse := domain.User{FirstName: "Carla", LastName: "Nueva", Email: users[2].Email, Phone: "+14155550101"}
+	assertAlreadyExists(t, repo.Create(ctx, &reuse), "email")
+}
+
+func testConcurrentCreateSameEmail(t *testing.T, repo user.Repository) {
+	ctx := context.Background()
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := domain.User{FirstName: "Race", LastName: fmt.Sprint(i), Email: "race@example.com", Phone: "+541145551111"}
+			errs[i] = repo.Create(ctx, &u)
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Fatalf("%d concurrent creates with the same email succeeded, want 1 (errors: %v)", created, errs)
+	}
+
+	count, err := repo.Count(ctx, user.Filters{Email: "race@example.com"})
+	if err != nil {
+		t.Fatalf("Count: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Count = %d, want 1", count)
+	}
+}
+
+func assertAlreadyExists(t *testing.T, err error, field string) {
+	t.Helper()
+
+	if !errors.Is(err, user.ErrUserAlreadyExists) {
+		t.Fatalf("expected error to wrap ErrUserAlreadyExists, got %v", err)
+	}
+	var existsErr *user.ErrAlreadyExists
+	if !errors.As(err, &existsErr) {
+		t.Fatalf("expected *user.ErrAlreadyExists, got %T: %v", err, err)
+	}
+	if existsErr.Field != field {
+		t.Fatalf("ErrAlreadyExists.Field = %q, want %q", existsErr.Field, field)
+	}
+}
+
+func assertNotFound(t *testing.T, err error, id string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("expected not found error for %s, got nil", id)
+	}
+	var notFound *user.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected *user.ErrNotFound, got %T: %v", err, err)
+	}
+	if notFound.UserID != id {
+		t.Fatalf("ErrNotFound.UserID = %q, want %q", notFound.UserID, id)
+	}
+	if !errors.Is(err, user.ErrNotFoundBase) {
+		t.Fatalf("expected error to wrap ErrNotFoundBase: %v", err)
+	}
+}
+
+func assertEmailNotFound(t *testing.T, err error, email string) {
+	t.Helper()
+
+	var notFound *user.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("expected *user.ErrNotFound for %s, got %T: %v", email, err, err)
+	}
+	if notFound.Email != email {
+		t.Fatalf("ErrNotFound.Email = %q, want %q", notFound.Email, email)
+	}
+	if !errors.Is(err, user.ErrNotFoundBase) {
+		t.Fatalf("expected error to wrap ErrNotFoundBase: %v", err)
+	}
+}
+
+func assertSameUser(t *testing.T, want, got domain.User) {
+	t.Helper()
+
+	if got.ID != want.ID || got.FirstName != want.FirstName || got.LastName != want.LastName ||
+		got.Email != want.Email || got.Phone != want.Phone {
+		t.Fatalf("user mismatch:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func assertIDs(t *testing.T, got []domain.User, want ...string) {
+	t.Helper()
+
+	ids := make([]string, len(got))
+	for i, u := range got {
+		ids[i] = u.ID
+	}
+	if len(ids) != len(want) {
+		t.Fatalf("got IDs %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("got IDs %v, want %v", ids, want)
+		}
+	}
+}
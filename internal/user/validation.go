@@ -0,0 +1,178 @@
+package user
+
+import (
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Límites alineados con las columnas de domain.User
+const (
+	maxNameLength  = 50
+	maxEmailLength = 50
+)
+
+// e164 acepta "+" seguido de hasta 15 dígitos sin ceros a la izquierda.
+// El servicio ya normalizó el teléfono, así que cualquier otro formato es un número inválido.
+var e164 = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// ErrValidation agrupa todos los problemas de validación, indexados por el nombre JSON del campo
+type ErrValidation struct {
+	Fields map[string][]string
+	// messages son los mismos problemas sin formatear, para traducirlos
+	messages map[string][]message
+}
+
+// Error implementa la interfaz error
+func (e *ErrValidation) Error() string {
+	return e.message().String()
+}
+
+func (e *ErrValidation) message() message {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	formats := make([]string, 0, len(fields))
+	args := []interface{}{newMessage(ErrValidationFailed.Error())}
+	for _, field := range fields {
+		messages := e.fieldMessages(field)
+		list := make([]interface{}, len(messages))
+		for i, m := range messages {
+			list[i] = m
+		}
+		formats = append(formats, "%s: %s")
+		args = append(args, field, newMessage(strings.TrimSuffix(strings.Repeat("%s, ", len(list)), ", "), list...))
+	}
+	return newMessage("%s: "+strings.Join(formats, "; "), args...)
+}
+
+// fieldMessages devuelve los problemas de field; si el error no se armó con validator son los textos de Fields
+func (e *ErrValidation) fieldMessages(field string) []message {
+	if messages, ok := e.messages[field]; ok {
+		return messages
+	}
+	messages := make([]message, len(e.Fields[field]))
+	for i, text := range e.Fields[field] {
+		messages[i] = newMessage(text)
+	}
+	return messages
+}
+
+// localizedFields devuelve Fields en lang
+func (e *ErrValidation) localizedFields(lang string) map[string][]string {
+	fields := make(map[string][]string, len(e.Fields))
+	for field := range e.Fields {
+		for _, m := range e.fieldMessages(field) {
+			fields[field] = append(fields[field], m.localize(lang))
+		}
+	}
+	return fields
+}
+
+// Unwrap permite usar errors.Is() con ErrValidationFailed
+func (e *ErrValidation) Unwrap() error {
+	return ErrValidationFailed
+}
+
+// validator acumula errores por campo para reportarlos todos juntos
+type validator struct {
+	fields   map[string][]string
+	messages map[string][]message
+}
+
+// add registra un problema de field; format puede tener verbos de fmt con sus args
+func (v *validator) add(field, format string, args ...interface{}) {
+	if v.fields == nil {
+		v.fields = make(map[string][]string)
+		v.messages = make(map[string][]message)
+	}
+	msg := newMessage(format, args...)
+	v.fields[field] = append(v.fields[field], msg.String())
+	v.messages[field] = append(v.messages[field], msg)
+}
+
+// err devuelve nil si no hubo problemas
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ErrValidation{Fields: v.fields, messages: v.messages}
+}
+
+// validateCreate valida un alta: todos los campos son obligatorios
+func validateCreate(firstName, lastName, email, phone string) error {
+	var v validator
+	v.requiredName("first_name", firstName, ErrFirstNameRequired)
+	v.requiredName("last_name", lastName, ErrLastNameRequired)
+	v.email(email, ErrEmailRequired)
+	v.phone(phone, ErrPhoneRequired)
+	return v.err()
+}
+
+// validateUpdate valida una actualización parcial: solo se revisan los campos enviados
+func validateUpdate(firstName, lastName, email, phone *string) error {
+	var v validator
+	if firstName != nil {
+		v.requiredName("first_name", *firstName, ErrFirstNameEmpty)
+	}
+	if lastName != nil {
+		v.requiredName("last_name", *lastName, ErrLastNameEmpty)
+	}
+	if email != nil {
+		v.email(*email, ErrEmailEmpty)
+	}
+	if phone != nil {
+		v.phone(*phone, ErrPhoneEmpty)
+	}
+	return v.err()
+}
+
+// validateID valida un ID elegido por el cliente: debe ser un UUID en su forma canónica
+func validateID(id string) error {
+	var v validator
+	if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+		v.add("id", "must be a valid UUID, e.g. 0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f")
+	}
+	return v.err()
+}
+
+func (v *validator) requiredName(field, value string, errEmpty error) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, errEmpty.Error())
+		return
+	}
+	if utf8.RuneCountInString(value) > maxNameLength {
+		v.add(field, "must be at most %d characters", maxNameLength)
+	}
+}
+
+func (v *validator) email(value string, errEmpty error) {
+	if strings.TrimSpace(value) == "" {
+		v.add("email", errEmpty.Error())
+		return
+	}
+	if utf8.RuneCountInString(value) > maxEmailLength {
+		v.add("email", "must be at most %d characters", maxEmailLength)
+	}
+	// ParseAddress acepta "Nombre <mail>", por eso exigimos que la dirección sea todo el valor
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		v.add("email", "must be a valid email address")
+	}
+}
+
+func (v *validator) phone(value string, errEmpty error) {
+	if strings.TrimSpace(value) == "" {
+		v.add("phone", errEmpty.Error())
+		return
+	}
+	if !e164.MatchString(value) {
+		v.add("phone", "must be a valid phone number, e.g. +5491145551234")
+	}
+}
@@ -0,0 +1,107 @@
+package user
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateCreate(t *testing.T) {
+	long := strings.Repeat("a", maxNameLength+1)
+
+	tests := []struct {
+		name                              string
+		firstName, lastName, email, phone string
+		want                              map[string][]string
+	}{
+		{
+			name:      "valid",
+			firstName: "Ana", lastName: "Gómez", email: "ana@example.com", phone: "+5491145551234",
+		},
+		{
+			name: "all missing",
+			want: map[string][]string{
+				"first_name": {"first name is required"},
+				"last_name":  {"last name is required"},
+				"email":      {"email is required"},
+				"phone":      {"phone is required"},
+			},
+		},
+		{
+			name:      "every field invalid",
+			firstName: long, lastName: "   ", email: "Ana <ana@example.com>", phone: "011 4555-1234",
+			want: map[string][]string{
+				"first_name": {"must be at most 50 characters"},
+				"last_name":  {"last name is required"},
+				"email":      {"must be a valid email address"},
+				"phone":      {"must be a valid phone number, e.g. +5491145551234"},
+			},
+		},
+		{
+			name:      "email too long and malformed",
+			firstName: "Ana", lastName: "Gomez", email: long + "@", phone: "+5491145551234",
+			want: map[string][]string{
+				"email": {"must be at most 50 characters", "must be a valid email address"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCreate(tt.firstName, tt.lastName, tt.email, tt.phone)
+			assertValidation(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	empty := ""
+	bad := "not-an-email"
+	phone := "+5491145551234"
+
+	// Los campos nil no se validan
+	if err := validateUpdate(nil, nil, nil, &phone); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := validateUpdate(&empty, nil, &bad, &empty)
+	assertValidation(t, err, map[string][]string{
+		"first_name": {"first name cannot be empty"},
+		"email":      {"must be a valid email address"},
+		"phone":      {"phone cannot be empty"},
+	})
+}
+
+func TestValidateID(t *testing.T) {
+	if err := validateID("0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string][]string{"id": {"must be a valid UUID, e.g. 0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"}}
+	for _, id := range []string{"42", "0B6F5B8E-3F5A-4C2E-9D8A-1A2B3C4D5E6F", "{0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f}"} {
+		assertValidation(t, validateID(id), want)
+	}
+}
+
+func assertValidation(t *testing.T, err error, want map[string][]string) {
+	t.Helper()
+
+	if want == nil {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	var validationErr *ErrValidation
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *ErrValidation, got %T: %v", err, err)
+	}
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("expected error to wrap ErrValidationFailed")
+	}
+	if !reflect.DeepEqual(validationErr.Fields, want) {
+		t.Fatalf("fields = %v, want %v", validationErr.Fields, want)
+	}
+}
@@ -1,41 +1,232 @@
-package bootstrap
-
-import (
-	"fmt"
-	"log"
-	"os"
-
-	"github.com/NicoJCastro/gocourse_domain/domain"
-
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-)
-
-func DBConnection() (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_HOST"),
-		os.Getenv("DATABASE_PORT"),
-		os.Getenv("DATABASE_NAME"),
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return nil, err
-	}
-	if os.Getenv("DATABASE_DEBUG") == "true" {
-		db = db.Debug()
-	}
-
-	if os.Getenv("DATABASE_MIGRATE") == "true" {
-		if err := db.AutoMigrate(&domain.User{}); err != nil {
-			return nil, err
-		}
-	}
-	return db, nil
-}
-
-func InitLogger() *log.Logger {
-	return log.New(os.Stdout, "user-api ", log.LstdFlags|log.Lshortfile)
-}
+package bootstrap
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_domain/domain"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Drivers soportados en DATABASE_DRIVER
+const (
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// sqliteLowerFunc es el LOWER Unicode que registramos en SQLite (el nativo solo convierte ASCII).
+// 💡 Tiene nombre propio en lugar de redefinir lower: el índice de email lo usa y otro cliente
+// (el CLI de sqlite3, un backup) que no lo tenga registrado falla explícitamente en vez de
+// evaluar el índice con otro lower. El repositorio usa el mismo nombre.
+const sqliteLowerFunc = "unicode_lower"
+
+var (
+	registerSQLiteFuncs sync.Once
+	// registerSQLiteErr es el resultado del registro: se devuelve en cada conexión, no solo en la primera
+	registerSQLiteErr error
+)
+
+func DBConnection() (*gorm.DB, error) {
+	var db *gorm.DB
+	var err error
+
+	switch driver := os.Getenv("DATABASE_DRIVER"); driver {
+	case "", DriverMySQL:
+		db, err = mysqlConnection()
+	case DriverPostgres:
+		db, err = postgresConnection()
+	case DriverSQLite:
+		// 💡 SQLite es un archivo embebido: siempre migramos para que el esquema exista
+		db, err = SQLiteConnection(sqlitePath())
+	default:
+		return nil, fmt.Errorf("unsupported DATABASE_DRIVER %q", driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if os.Getenv("DATABASE_DEBUG") == "true" {
+		db = db.Debug()
+	}
+
+	if os.Getenv("DATABASE_MIGRATE") == "true" {
+		if err := Migrate(db); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func mysqlConnection() (*gorm.DB, error) {
+	dsn := fmt.Sprintf("%s:%s@(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		os.Getenv("DATABASE_USER"),
+		os.Getenv("DATABASE_PASSWORD"),
+		os.Getenv("DATABASE_HOST"),
+		os.Getenv("DATABASE_PORT"),
+		os.Getenv("DATABASE_NAME"),
+	)
+
+	return gorm.Open(mysql.Open(dsn), &gorm.Config{
+		// MySQL guarda datetime(3) redondeando: truncamos a milisegundos para que
+		// el updated_at que devolvemos al crear coincida con el guardado (ETag)
+		NowFunc: func() time.Time { return time.Now().Local().Truncate(time.Millisecond) },
+	})
+}
+
+func postgresConnection() (*gorm.DB, error) {
+	sslMode := os.Getenv("DATABASE_SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		os.Getenv("DATABASE_HOST"),
+		os.Getenv("DATABASE_PORT"),
+		os.Getenv("DATABASE_USER"),
+		os.Getenv("DATABASE_PASSWORD"),
+		os.Getenv("DATABASE_NAME"),
+		sslMode,
+	)
+
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+}
+
+// sqlitePath usa DATABASE_PATH o, si no está, DATABASE_NAME.db
+func sqlitePath() string {
+	if path := os.Getenv("DATABASE_PATH"); path != "" {
+		return path
+	}
+	return os.Getenv("DATABASE_NAME") + ".db"
+}
+
+// SQLiteConnection abre (o crea) la base SQLite del archivo path y migra el esquema
+func SQLiteConnection(path string) (*gorm.DB, error) {
+	registerSQLiteFuncs.Do(func() {
+		// 🔧 Los filtros usan unicode_lower(...) para comportarse igual que LOWER en MySQL
+		registerSQLiteErr = gosqlite.RegisterDeterministicScalarFunction(sqliteLowerFunc, 1, sqliteLower)
+	})
+	if registerSQLiteErr != nil {
+		return nil, registerSQLiteErr
+	}
+
+	dsn := path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		// SQLite guarda las fechas como texto: usamos siempre UTC para que se comparen bien
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func sqliteLower(_ *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	switch v := args[0].(type) {
+	case string:
+		return strings.ToLower(v), nil
+	case []byte:
+		return strings.ToLower(string(v)), nil
+	default:
+		return v, nil
+	}
+}
+
+// postgresUser es el esquema de users para Postgres.
+// En Postgres char(n) rellena con espacios al leer, así que usamos varchar con los mismos tamaños que domain.User.
+type postgresUser struct {
+	ID        string `gorm:"type:char(36);not null;primaryKey"`
+	FirstName string `gorm:"type:varchar(50);not null"`
+	LastName  string `gorm:"type:varchar(50);not null"`
+	Email     string `gorm:"type:varchar(50);not null"`
+	Phone     string `gorm:"type:varchar(30);not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Deleted   gorm.DeletedAt
+}
+
+func (postgresUser) TableName() string {
+	return "users"
+}
+
+// Migrate crea o actualiza las tablas del servicio
+func Migrate(db *gorm.DB) error {
+	var err error
+	if db.Dialector.Name() == DriverPostgres {
+		err = db.AutoMigrate(&postgresUser{})
+	} else {
+		err = db.AutoMigrate(&domain.User{})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := createEmailIndex(db); err != nil {
+		return err
+	}
+	if err := createCursorIndex(db); err != nil {
+		return err
+	}
+	return createUpdatedAtIndex(db)
+}
+
+// createEmailIndex crea el índice único sobre LOWER(email).
+// 🎯 Es lo que garantiza que dos altas concurrentes con el mismo email no prosperen.
+// Los usuarios borrados lógicamente también reservan su email, así pueden restaurarse.
+func createEmailIndex(db *gorm.DB) error {
+	name, expr := "idx_users_email_lower", "(LOWER(email))"
+	switch db.Dialector.Name() {
+	case DriverMySQL:
+		// MySQL (8.0.13+) exige doble paréntesis en los índices funcionales
+		expr = "((LOWER(email)))"
+	case DriverSQLite:
+		name, expr = "idx_users_email_unicode_lower", "("+sqliteLowerFunc+"(email))"
+	}
+
+	if !db.Migrator().HasIndex("users", name) {
+		if err := db.Exec("CREATE UNIQUE INDEX " + name + " ON users " + expr).Error; err != nil {
+			return err
+		}
+	}
+	if db.Dialector.Name() == DriverSQLite {
+		// 🔧 Las bases anteriores tienen el índice sobre el lower redefinido: se reemplaza por el nuevo
+		return db.Exec("DROP INDEX IF EXISTS idx_users_email_lower").Error
+	}
+	return nil
+}
+
+// createCursorIndex crea el índice (created_at, id) que usa la paginación por cursor
+func createCursorIndex(db *gorm.DB) error {
+	const name = "idx_users_created_at_id"
+	if db.Migrator().HasIndex("users", name) {
+		return nil
+	}
+	return db.Exec("CREATE INDEX " + name + " ON users (created_at, id)").Error
+}
+
+// createUpdatedAtIndex crea el índice sobre updated_at: MAX(updated_at) es parte del ETag de los listados
+func createUpdatedAtIndex(db *gorm.DB) error {
+	const name = "idx_users_updated_at"
+	if db.Migrator().HasIndex("users", name) {
+		return nil
+	}
+	return db.Exec("CREATE INDEX " + name + " ON users (updated_at)").Error
+}
+
+func InitLogger() *log.Logger {
+	return log.New(os.Stdout, "user-api ", log.LstdFlags|log.Lshortfile)
+}
@@ -0,0 +1,504 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+	"github.com/NicoJCastro/gocourse_domain/domain"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+)
+
+// openAPIPath es donde se sirve la especificación OpenAPI del servicio
+const openAPIPath = "/openapi.json"
+
+// route es una ruta HTTP del servicio: cómo se atiende y cómo se documenta en /openapi.json
+type route struct {
+	method string
+	path   string
+	// contentType restringe la ruta a ese Content-Type (ej: las variantes de PATCH /users/{id})
+	contentType string
+	endpoint    endpoint.Endpoint
+	decode      httptransport.DecodeRequestFunc
+	doc         operation
+}
+
+// operation documenta una ruta. Los schemas se generan a partir de los tipos de Go de body y de cada respuesta;
+// los parámetros de path salen del path de la ruta.
+type operation struct {
+	id      string
+	summary string
+	params  []param
+	// body es un valor del tipo del body (nil si no lleva), con el Content-Type de la ruta
+	body      interface{}
+	responses []result
+}
+
+// param es un parámetro de query o header; value es un valor de su tipo
+type param struct {
+	name        string
+	in          string
+	description string
+	value       interface{}
+}
+
+// result es una respuesta exitosa; body es un valor de su tipo (nil si no tiene body)
+type result struct {
+	status      int
+	description string
+	body        interface{}
+	headers     []string
+}
+
+const (
+	inQuery  = "query"
+	inHeader = "header"
+	inPath   = "path"
+)
+
+// Headers de respuesta documentados en components.headers
+const (
+	headerETag               = "ETag"
+	headerLastModified       = "Last-Modified"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+)
+
+var (
+	idempotencyKeyParam = param{"Idempotency-Key", inHeader, "Retries with the same key replay the first response instead of creating the user again", ""}
+	ifMatchParam        = param{"If-Match", inHeader, `ETag of the version being modified, or "*"; a stale ETag fails with 412`, ""}
+	dryRunParam         = param{"dry_run", inQuery, "Only count the users that would change", false}
+
+	cacheParams = []param{
+		{"If-None-Match", inHeader, "ETags the client already has: responds 304 if one matches", ""},
+		{"If-Modified-Since", inHeader, "HTTP date: responds 304 if nothing changed since then", ""},
+	}
+
+	versionHeaders = []string{headerETag, headerLastModified}
+)
+
+// filterOpDescriptions describe los operadores de los filtros campo[op]=valor
+var filterOpDescriptions = map[user.FilterOp]string{
+	user.OpContains: "contains the value",
+	user.OpPrefix:   "starts with the value",
+	user.OpEq:       "is the value",
+	user.OpIn:       "is one of the comma-separated values",
+}
+
+// filterParams son los filtros de GET /users; las operaciones masivas los usan para seleccionar usuarios
+func filterParams() []param {
+	params := []param{
+		{"ids", inQuery, "Comma-separated user IDs", ""},
+		{"first_name", inQuery, "First name contains the value", ""},
+		{"last_name", inQuery, "Last name contains the value", ""},
+		{"email", inQuery, "Email contains the value", ""},
+		{"phone", inQuery, "Phone contains the value", ""},
+		{"q", inQuery, "Free text search over names, email and phone; every term must match", ""},
+		{"created_after", inQuery, "Created at or after (RFC 3339)", time.Time{}},
+		{"created_before", inQuery, "Created before (RFC 3339)", time.Time{}},
+		{"updated_after", inQuery, "Updated at or after (RFC 3339)", time.Time{}},
+		{"updated_before", inQuery, "Updated before (RFC 3339)", time.Time{}},
+	}
+	for _, field := range user.FilterFields() {
+		for _, op := range user.FilterOps() {
+			description := fmt.Sprintf("%s %s (case-insensitive)", field, filterOpDescriptions[op])
+			params = append(params, param{field + "[" + string(op) + "]", inQuery, description, ""})
+		}
+	}
+	return params
+}
+
+// listParams son los parámetros de los listados: paginación, orden, filtros y GET condicional
+func listParams() []param {
+	params := []param{
+		{"limit", inQuery, "Users per page", 0},
+		{"page", inQuery, "Page number, starting at 1 (offset pagination)", 0},
+		{"sort", inQuery, "Comma-separated fields, with - for descending order: " + strings.Join(user.SortFields(), ", "), ""},
+		{"cursor", inQuery, "Keyset pagination: empty for the first page, then meta.next_cursor of the previous one", ""},
+	}
+	params = append(params, filterParams()...)
+	return append(params, cacheParams...)
+}
+
+// listResults son las respuestas de los listados: paginados por offset (meta.Meta) o por cursor
+func listResults() []result {
+	return []result{
+		{http.StatusOK, "A page of users: offset pagination, or keyset pagination when cursor is sent", []interface{}{userListEnvelope{}, userPageEnvelope{}}, versionHeaders},
+		{http.StatusNotModified, "The client already has the current list", nil, versionHeaders},
+	}
+}
+
+// Sobres de respuesta con su data concreta. Solo documentan: en response.SuccessResponse
+// (y en las respuestas de user) data es interface{}.
+type (
+	userEnvelope struct {
+		response.SuccessResponse
+		Data domain.User `json:"data"`
+	}
+
+	userListEnvelope struct {
+		response.SuccessResponse
+		Data []domain.User `json:"data"`
+	}
+
+	userPageEnvelope struct {
+		user.CursorResponse
+		Data []domain.User `json:"data"`
+	}
+
+	userBatchItem struct {
+		user.BatchItem
+		Data  *domain.User  `json:"data,omitempty"`
+		Error *user.Problem `json:"error,omitempty"`
+	}
+
+	userBatchEnvelope struct {
+		user.BatchResponse
+		Data []userBatchItem `json:"data"`
+	}
+
+	bulkEnvelope struct {
+		response.SuccessResponse
+		Data user.BulkResult `json:"data"`
+	}
+
+	errorCatalogEnvelope struct {
+		response.SuccessResponse
+		Data []user.ErrorCatalogEntry `json:"data"`
+	}
+)
+
+// Documento OpenAPI 3.1: solo los campos que usamos
+type (
+	openAPIDocument struct {
+		OpenAPI    string                                  `json:"openapi"`
+		Info       openAPIInfo                             `json:"info"`
+		Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+		Components openAPIComponents                       `json:"components"`
+	}
+
+	openAPIInfo struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	}
+
+	openAPIOperation struct {
+		OperationID string                      `json:"operationId"`
+		Summary     string                      `json:"summary"`
+		Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+		RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+		Responses   map[string]*openAPIResponse `json:"responses"`
+	}
+
+	openAPIParameter struct {
+		Ref         string         `json:"$ref,omitempty"`
+		Name        string         `json:"name,omitempty"`
+		In          string         `json:"in,omitempty"`
+		Description string         `json:"description,omitempty"`
+		Required    bool           `json:"required,omitempty"`
+		Schema      *openAPISchema `json:"schema,omitempty"`
+	}
+
+	openAPIRequestBody struct {
+		Required bool                        `json:"required"`
+		Content  map[string]openAPIMediaType `json:"content"`
+	}
+
+	openAPIMediaType struct {
+		Schema *openAPISchema `json:"schema"`
+	}
+
+	openAPIResponse struct {
+		Ref         string                      `json:"$ref,omitempty"`
+		Description string                      `json:"description,omitempty"`
+		Headers     map[string]*openAPIHeader   `json:"headers,omitempty"`
+		Content     map[string]openAPIMediaType `json:"content,omitempty"`
+	}
+
+	openAPIHeader struct {
+		Ref         string         `json:"$ref,omitempty"`
+		Description string         `json:"description,omitempty"`
+		Schema      *openAPISchema `json:"schema,omitempty"`
+	}
+
+	openAPIComponents struct {
+		Schemas    map[string]*openAPISchema    `json:"schemas"`
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+		Headers    map[string]*openAPIHeader    `json:"headers"`
+		Responses  map[string]*openAPIResponse  `json:"responses"`
+	}
+
+	// openAPISchema es un JSON Schema (2020-12, el dialecto de OpenAPI 3.1)
+	openAPISchema struct {
+		Ref                  string                    `json:"$ref,omitempty"`
+		Type                 string                    `json:"type,omitempty"`
+		Format               string                    `json:"format,omitempty"`
+		Items                *openAPISchema            `json:"items,omitempty"`
+		Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+		Required             []string                  `json:"required,omitempty"`
+		AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+		OneOf                []*openAPISchema          `json:"oneOf,omitempty"`
+	}
+)
+
+// newOpenAPIDocument genera la especificación de routes (más la de /openapi.json)
+func newOpenAPIDocument(routes []route) *openAPIDocument {
+	b := &schemaBuilder{schemas: make(map[string]*openAPISchema), types: make(map[string]reflect.Type)}
+	doc := &openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info: openAPIInfo{
+			Title:       "Users API",
+			Description: "Users service. Errors are application/problem+json (RFC 9457) with a stable code: see GET /errors.",
+			Version:     "1.0.0",
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: b.schemas,
+			Parameters: map[string]*openAPIParameter{
+				"Accept-Language": {Name: "Accept-Language", In: inHeader, Description: "Language of the messages: " + strings.Join(user.Languages(), ", ") + " (English if none matches)", Schema: &openAPISchema{Type: "string"}},
+				RequestIDHeader:   {Name: RequestIDHeader, In: inHeader, Description: "Request ID, echoed in the response and in the errors; generated if missing", Schema: &openAPISchema{Type: "string"}},
+			},
+			Headers: map[string]*openAPIHeader{
+				headerETag:               {Description: "Version of the resource in the response language (Accept-Language), for If-Match and If-None-Match", Schema: &openAPISchema{Type: "string"}},
+				headerLastModified:       {Description: "Last update of the resource (HTTP date)", Schema: &openAPISchema{Type: "string"}},
+				headerIdempotentReplayed: {Description: "true when the response replays an earlier request with the same Idempotency-Key", Schema: &openAPISchema{Type: "string"}},
+			},
+			Responses: map[string]*openAPIResponse{
+				"Problem": {
+					Description: "Error (RFC 9457); code identifies it",
+					Content:     map[string]openAPIMediaType{user.ContentTypeProblem: {Schema: b.schemaOf(reflect.TypeOf(user.Problem{}))}},
+				},
+			},
+		},
+	}
+
+	for _, rt := range routes {
+		doc.addOperation(b, rt)
+	}
+	doc.addPath(openAPIPath, http.MethodGet, &openAPIOperation{
+		OperationID: "getOpenAPI",
+		Summary:     "This OpenAPI document",
+		Responses: map[string]*openAPIResponse{
+			strconv.Itoa(http.StatusOK): {Description: "OpenAPI 3.1 document", Content: map[string]openAPIMediaType{"application/json": {Schema: &openAPISchema{Type: "object"}}}},
+			"default":                   {Ref: "#/components/responses/Problem"},
+		},
+	})
+	return doc
+}
+
+func (doc *openAPIDocument) addPath(path, method string, op *openAPIOperation) {
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = make(map[string]*openAPIOperation)
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)`)
+
+// pathParamDescriptions describe los parámetros de path de las rutas
+var pathParamDescriptions = map[string]string{
+	"id":    "User ID (UUID)",
+	"email": "User email",
+}
+
+// addOperation documenta rt. Las rutas con el mismo método y path (las variantes por Content-Type
+// de PATCH /users/{id}) son una sola operación con un body por Content-Type.
+func (doc *openAPIDocument) addOperation(b *schemaBuilder, rt route) {
+	contentType := rt.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	if existing := doc.Paths[rt.path][strings.ToLower(rt.method)]; existing != nil {
+		if rt.doc.body != nil {
+			existing.RequestBody.Content[contentType] = openAPIMediaType{Schema: b.schemaOf(reflect.TypeOf(rt.doc.body))}
+		}
+		return
+	}
+
+	op := &openAPIOperation{
+		OperationID: rt.doc.id,
+		Summary:     rt.doc.summary,
+		Responses:   map[string]*openAPIResponse{"default": {Ref: "#/components/responses/Problem"}},
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name: m[1], In: inPath, Description: pathParamDescriptions[m[1]], Required: true, Schema: &openAPISchema{Type: "string"},
+		})
+	}
+	for _, p := range rt.doc.params {
+		op.Parameters = append(op.Parameters, &openAPIParameter{
+			Name: p.name, In: p.in, Description: p.description, Schema: b.schemaOf(reflect.TypeOf(p.value)),
+		})
+	}
+	op.Parameters = append(op.Parameters,
+		&openAPIParameter{Ref: "#/components/parameters/Accept-Language"},
+		&openAPIParameter{Ref: "#/components/parameters/" + RequestIDHeader},
+	)
+
+	if rt.doc.body != nil {
+		op.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMediaType{contentType: {Schema: b.schemaOf(reflect.TypeOf(rt.doc.body))}},
+		}
+	}
+
+	for _, res := range rt.doc.responses {
+		resp := &openAPIResponse{Description: res.description}
+		for _, name := range res.headers {
+			if resp.Headers == nil {
+				resp.Headers = make(map[string]*openAPIHeader)
+			}
+			resp.Headers[name] = &openAPIHeader{Ref: "#/components/headers/" + name}
+		}
+		if res.body != nil {
+			resp.Content = map[string]openAPIMediaType{"application/json": {Schema: b.bodySchema(res.body)}}
+		}
+		op.Responses[strconv.Itoa(res.status)] = resp
+	}
+
+	doc.addPath(rt.path, rt.method, op)
+}
+
+// schemaBuilder genera los schemas a partir de los tipos de Go; los structs van a components.schemas
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+	types   map[string]reflect.Type
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// bodySchema es el schema de body; una lista de valores son respuestas alternativas (oneOf)
+func (b *schemaBuilder) bodySchema(body interface{}) *openAPISchema {
+	alternatives, ok := body.([]interface{})
+	if !ok {
+		return b.schemaOf(reflect.TypeOf(body))
+	}
+	s := &openAPISchema{}
+	for _, alternative := range alternatives {
+		s.OneOf = append(s.OneOf, b.schemaOf(reflect.TypeOf(alternative)))
+	}
+	return s
+}
+
+// schemaOf devuelve el schema de t como lo serializa encoding/json
+func (b *schemaBuilder) schemaOf(t reflect.Type) *openAPISchema {
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		// Cualquier valor JSON
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schemaOf(t.Elem())
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return b.ref(t)
+	}
+	// interface{} (ej: data de response.SuccessResponse): cualquier valor
+	return &openAPISchema{}
+}
+
+// ref registra el struct t en components.schemas y devuelve una referencia
+func (b *schemaBuilder) ref(t reflect.Type) *openAPISchema {
+	name := t.Name()
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if existing, ok := b.types[name]; ok && existing != t {
+		panic(fmt.Sprintf("openapi: %s and %s have the same schema name", existing, t))
+	}
+
+	if _, ok := b.types[name]; !ok {
+		b.types[name] = t
+		s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+		for name, field := range jsonFields(t) {
+			s.Properties[name] = b.schemaOf(field.Type)
+			_, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			// Sin omitempty y sin puntero, el campo siempre está
+			if field.Type.Kind() != reflect.Ptr && !strings.Contains(options, "omitempty") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		sort.Strings(s.Required)
+		b.schemas[name] = s
+	}
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+// jsonFields devuelve los campos de t por su nombre en JSON. Los propios reemplazan a los de los
+// structs embebidos, como en encoding/json.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			for name, embedded := range jsonFields(field.Type) {
+				fields[name] = embedded
+			}
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// openAPIHandler sirve la especificación de routes; se genera una sola vez
+func openAPIHandler(routes []route) http.Handler {
+	var once sync.Once
+	var spec []byte
+	var err error
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			spec, err = json.MarshalIndent(newOpenAPIDocument(routes), "", "  ")
+		})
+		if err != nil {
+			encodeError(r.Context(), err, w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(spec)
+	})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+)
+
+// 🔧 go test ./pkg/handler -run TestOpenAPI -update regenera testdata/openapi.json
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json with the generated spec")
+
+var openAPIGolden = filepath.Join("testdata", "openapi.json")
+
+// getOpenAPI pide la especificación al servidor, como lo haría un cliente
+func getOpenAPI(t *testing.T) []byte {
+	t.Helper()
+	srv := httptest.NewServer(NewUserHTTPServer(context.Background(), user.Endpoint{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + openAPIPath)
+	if err != nil {
+		t.Fatalf("GET %s: %v", openAPIPath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("GET %s: status %d, content type %q", openAPIPath, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading spec: %v", err)
+	}
+	return body
+}
+
+// 🎯 La especificación servida es la versionada en testdata: si cambian las rutas o los tipos,
+// hay que regenerarla y el diff muestra el cambio de contrato
+func TestOpenAPIGolden(t *testing.T) {
+	spec := getOpenAPI(t)
+	if *update {
+		if err := os.WriteFile(openAPIGolden, spec, 0o644); err != nil {
+			t.Fatalf("writing %s: %v", openAPIGolden, err)
+		}
+	}
+
+	golden, err := os.ReadFile(openAPIGolden)
+	if err != nil {
+		t.Fatalf("reading %s: %v", openAPIGolden, err)
+	}
+	if !bytes.Equal(spec, golden) {
+		t.Errorf("%s is out of date: run go test ./pkg/handler -run TestOpenAPI -update", openAPIGolden)
+	}
+}
+
+// 🎯 Cada ruta del router está documentada, con sus parámetros de path
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(getOpenAPI(t), &doc); err != nil {
+		t.Fatalf("decoding spec: %v", err)
+	}
+
+	routes := 0
+	err := newRouter(user.Endpoint{}).Walk(func(r *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := r.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := r.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			routes++
+			op := doc.Paths[path][strings.ToLower(method)]
+			if op == nil {
+				t.Errorf("%s %s is not in the spec", method, path)
+				continue
+			}
+			if op.OperationID == "" || op.Responses["default"] == nil {
+				t.Errorf("%s %s: operationId %q, responses %v", method, path, op.OperationID, op.Responses)
+			}
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				if !hasParameter(op, m[1], inPath) {
+					t.Errorf("%s %s: path parameter %s is not documented", method, path, m[1])
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking routes: %v", err)
+	}
+	if routes == 0 {
+		t.Fatal("the router has no routes")
+	}
+
+	// Los filtros de campo[op] salen de los de user, así que siempre están todos
+	list := doc.Paths["/users"]["get"]
+	for _, field := range user.FilterFields() {
+		for _, op := range user.FilterOps() {
+			if name := field + "[" + string(op) + "]"; !hasParameter(list, name, inQuery) {
+				t.Errorf("GET /users: filter %s is not documented", name)
+			}
+		}
+	}
+}
+
+// 🎯 Todas las referencias apuntan a componentes que existen
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var spec interface{}
+	if err := json.Unmarshal(getOpenAPI(t), &spec); err != nil {
+		t.Fatalf("decoding spec: %v", err)
+	}
+	components := spec.(map[string]interface{})["components"].(map[string]interface{})
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+				section, _ := components[parts[0]].(map[string]interface{})
+				if len(parts) != 2 || section[parts[1]] == nil {
+					t.Errorf("unresolved $ref %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+func hasParameter(op *openAPIOperation, name, in string) bool {
+	for _, p := range op.Parameters {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
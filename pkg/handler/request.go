@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader identifica cada request; se acepta el del cliente o se genera uno
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength evita reflejar en la respuesta valores arbitrariamente largos
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	instanceKey
+)
+
+// withRequestID asigna un ID a cada request, lo devuelve en X-Request-ID y lo deja
+// en el contexto (junto con la ruta pedida) para el instance y request_id de los problemas
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = context.WithValue(ctx, instanceKey, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withLanguage elige el idioma de los mensajes según Accept-Language (inglés si no tenemos ninguno
+// de los pedidos), lo informa en Content-Language y lo deja en el contexto para los encoders
+func withLanguage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := user.MatchLanguage(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+
+		next.ServeHTTP(w, r.WithContext(user.WithLanguage(r.Context(), lang)))
+	})
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func instanceFromContext(ctx context.Context) string {
+	instance, _ := ctx.Value(instanceKey).(string)
+	return instance
+}
@@ -1,169 +1,646 @@
-package handler
-
-import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"strconv"
-
-	"github.com/go-kit/kit/endpoint"
-	httptransport "github.com/go-kit/kit/transport/http"
-
-	"github.com/NicoJCastro/go_lib_response/response"
-	"github.com/NicoJCastro/gocourse_user/internal/user"
-
-	"github.com/gorilla/mux"
-)
-
-func NewUserHTTPServer(ctx context.Context, endpoints user.Endpoint) http.Handler {
-	mux := mux.NewRouter()
-
-	opts := []httptransport.ServerOption{
-		httptransport.ServerErrorEncoder(encodeError),
-	}
-
-	// 🎯 POST /users - Crear usuario
-	mux.Handle("/users", httptransport.NewServer(
-		endpoint.Endpoint(endpoints.Create),
-		decodeStoreUser,
-		encodeResponse,
-		opts...,
-	)).Methods("POST")
-
-	// 🎯 GET /users/{id} - Obtener un usuario por ID
-	mux.Handle("/users/{id}", httptransport.NewServer(
-		endpoint.Endpoint(endpoints.Get),
-		decodeGetUser,
-		encodeResponse,
-		opts...,
-	)).Methods("GET")
-
-	// 🎯 GET /users - Obtener todos los usuarios (con paginación y filtros)
-	mux.Handle("/users", httptransport.NewServer(
-		endpoint.Endpoint(endpoints.GetAll),
-		decodeGetAllUsers,
-		encodeResponse,
-		opts...,
-	)).Methods("GET")
-
-	// 🎯 PATCH /users/{id} - Actualizar usuario
-	mux.Handle("/users/{id}", httptransport.NewServer(
-		endpoint.Endpoint(endpoints.Update),
-		decodeUpdateUser,
-		encodeResponse,
-		opts...,
-	)).Methods("PATCH")
-
-	// 🎯 DELETE /users/{id} - Eliminar usuario
-	mux.Handle("/users/{id}", httptransport.NewServer(
-		endpoint.Endpoint(endpoints.Delete),
-		decodeDeleteUser,
-		encodeResponse,
-		opts...,
-	)).Methods("DELETE")
-	return mux
-}
-
-// 🎯 Decoder para CREATE: decodifica el body JSON
-func decodeStoreUser(_ context.Context, r *http.Request) (interface{}, error) {
-	var req user.CreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
-	}
-	return req, nil
-}
-
-// 🎯 Decoder para GET: extrae el ID de la URL
-func decodeGetUser(_ context.Context, r *http.Request) (interface{}, error) {
-	vars := mux.Vars(r)
-	id, ok := vars["id"]
-	if !ok || id == "" {
-		return nil, user.ErrIDRequired
-	}
-	return user.GetRequest{ID: id}, nil
-}
-
-// 🎯 Decoder para GET ALL: extrae query parameters (limit, page, filters)
-func decodeGetAllUsers(_ context.Context, r *http.Request) (interface{}, error) {
-	// Extraer query parameters
-	query := r.URL.Query()
-
-	// Convertir limit y page a int
-	limit := 0
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil {
-			limit = l
-		}
-	}
-
-	page := 0
-	if pageStr := query.Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil {
-			page = p
-		}
-	}
-
-	// Construir GetAllRequest con los query parameters
-	req := user.GetAllRequest{
-		FirstName: query.Get("first_name"),
-		LastName:  query.Get("last_name"),
-		Email:     query.Get("email"),
-		Phone:     query.Get("phone"),
-		Limit:     limit,
-		Page:      page,
-	}
-
-	return req, nil
-}
-
-// 🎯 Decoder para UPDATE: extrae ID de la URL y body JSON
-func decodeUpdateUser(_ context.Context, r *http.Request) (interface{}, error) {
-	vars := mux.Vars(r)
-	id, ok := vars["id"]
-	if !ok || id == "" {
-		return nil, user.ErrIDRequired
-	}
-
-	var req user.UpdateRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, user.ErrInvalidRequestType
-	}
-
-	// Asignar el ID extraído de la URL
-	req.ID = id
-	return req, nil
-}
-
-// 🎯 Decoder para DELETE: extrae el ID de la URL
-func decodeDeleteUser(_ context.Context, r *http.Request) (interface{}, error) {
-	vars := mux.Vars(r)
-	id, ok := vars["id"]
-	if !ok || id == "" {
-		return nil, user.ErrIDRequired
-	}
-	return user.DeleteRequest{ID: id}, nil
-}
-
-// 🎯 Encoder para todas las respuestas
-func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
-	respObj := resp.(response.Response)
-
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(respObj.StatusCode())
-	return json.NewEncoder(w).Encode(respObj)
-}
-
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-
-	// 🔍 Intentamos convertir el error a response.Response
-	resp, ok := err.(response.Response)
-	if !ok {
-		// ❌ Si no es response.Response, es un error estándar de Go
-		// 💡 Lo convertimos a InternalServerError como fallback seguro
-		resp = response.InternalServerError(err.Error())
-	}
-
-	w.WriteHeader(resp.StatusCode())
-	_ = json.NewEncoder(w).Encode(resp)
-}
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/NicoJCastro/go_lib_response/response"
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+
+	"github.com/gorilla/mux"
+)
+
+func NewUserHTTPServer(ctx context.Context, endpoints user.Endpoint) http.Handler {
+	return withRequestID(withLanguage(newRouter(endpoints)))
+}
+
+// newRouter registra las rutas de userRoutes y la especificación OpenAPI que se genera a partir de ellas
+func newRouter(endpoints user.Endpoint) *mux.Router {
+	mux := mux.NewRouter()
+
+	opts := []httptransport.ServerOption{
+		httptransport.ServerErrorEncoder(encodeError),
+	}
+
+	routes := userRoutes(endpoints)
+	for _, rt := range routes {
+		r := mux.Handle(rt.path, httptransport.NewServer(
+			rt.endpoint,
+			rt.decode,
+			encodeResponse,
+			opts...,
+		)).Methods(rt.method)
+		if rt.contentType != "" {
+			r.HeadersRegexp("Content-Type", contentTypeRegexp(rt.contentType))
+		}
+	}
+
+	// 🎯 GET /openapi.json - Especificación OpenAPI 3.1 de las rutas anteriores
+	mux.Handle(openAPIPath, openAPIHandler(routes)).Methods(http.MethodGet)
+
+	return mux
+}
+
+// userRoutes son las rutas del servicio en orden de registro.
+// 💡 Las más específicas van primero: /users/deleted antes que /users/{id} y los PATCH con Content-Type antes que el genérico.
+func userRoutes(endpoints user.Endpoint) []route {
+	return []route{
+		// 🎯 POST /users - Crear usuario
+		{
+			method: http.MethodPost, path: "/users",
+			endpoint: endpoint.Endpoint(endpoints.Create), decode: decodeStoreUser,
+			doc: operation{
+				id: "createUser", summary: "Create a user",
+				params:    []param{idempotencyKeyParam},
+				body:      user.CreateRequest{},
+				responses: []result{{http.StatusCreated, "User created", userEnvelope{}, []string{headerIdempotentReplayed}}},
+			},
+		},
+		// 🎯 POST /users/batch - Alta masiva (?atomic=true para todo o nada)
+		{
+			method: http.MethodPost, path: "/users/batch",
+			endpoint: endpoint.Endpoint(endpoints.CreateBatch), decode: decodeStoreUsers,
+			doc: operation{
+				id: "createUsers", summary: "Create several users; with atomic=true, all or none",
+				params: []param{{"atomic", inQuery, "Create all the users or none: the response status is the one of the first failure", false}},
+				body:   []user.CreateRequest{},
+				responses: []result{
+					{http.StatusCreated, "All the users were created", userBatchEnvelope{}, nil},
+					{http.StatusMultiStatus, "Some users were not created: see the status and error of each item", userBatchEnvelope{}, nil},
+				},
+			},
+		},
+		// 🎯 PATCH /users/batch - Actualización masiva por ids o filtros (?dry_run=true para simular)
+		{
+			method: http.MethodPatch, path: "/users/batch",
+			endpoint: endpoint.Endpoint(endpoints.UpdateBatch), decode: decodeUpdateUsers,
+			doc: operation{
+				id: "updateUsers", summary: "Update the users selected by ids or filters",
+				params:    append(filterParams(), dryRunParam),
+				body:      user.UpdateBatchRequest{},
+				responses: []result{{http.StatusOK, "Users updated", bulkEnvelope{}, nil}},
+			},
+		},
+		// 🎯 DELETE /users/batch - Borrado masivo por ids o filtros (?dry_run=true para simular)
+		{
+			method: http.MethodDelete, path: "/users/batch",
+			endpoint: endpoint.Endpoint(endpoints.DeleteBatch), decode: decodeDeleteUsers,
+			doc: operation{
+				id: "deleteUsers", summary: "Move the users selected by ids or filters to the trash",
+				params:    append(filterParams(), dryRunParam),
+				responses: []result{{http.StatusOK, "Users deleted", bulkEnvelope{}, nil}},
+			},
+		},
+		// 🎯 GET /users/deleted - Papelera: usuarios borrados (mismos filtros y paginación que GET /users)
+		{
+			method: http.MethodGet, path: "/users/deleted",
+			endpoint: endpoint.Endpoint(endpoints.GetAll), decode: decodeGetDeletedUsers,
+			doc: operation{
+				id: "listDeletedUsers", summary: "List the users in the trash",
+				params:    listParams(),
+				responses: listResults(),
+			},
+		},
+		// 🎯 GET /users/{id} - Obtener un usuario por ID
+		{
+			method: http.MethodGet, path: "/users/{id}",
+			endpoint: endpoint.Endpoint(endpoints.Get), decode: decodeGetUser,
+			doc: operation{
+				id: "getUser", summary: "Get a user by ID",
+				params: cacheParams,
+				responses: []result{
+					{http.StatusOK, "The user", userEnvelope{}, versionHeaders},
+					{http.StatusNotModified, "The client already has the current version", nil, versionHeaders},
+				},
+			},
+		},
+		// 🎯 GET /users/by-email/{email} - Obtener un usuario por email
+		{
+			method: http.MethodGet, path: "/users/by-email/{email}",
+			endpoint: endpoint.Endpoint(endpoints.GetByEmail), decode: decodeGetUserByEmail,
+			doc: operation{
+				id: "getUserByEmail", summary: "Get a user by email",
+				responses: []result{{http.StatusOK, "The user", userEnvelope{}, nil}},
+			},
+		},
+		// 🎯 GET /users - Listar usuarios (con paginación y filtros)
+		{
+			method: http.MethodGet, path: "/users",
+			endpoint: endpoint.Endpoint(endpoints.GetAll), decode: decodeGetAllUsers,
+			doc: operation{
+				id: "listUsers", summary: "List users",
+				params:    listParams(),
+				responses: listResults(),
+			},
+		},
+		// 🎯 PATCH /users/{id} con Content-Type application/merge-patch+json (RFC 7396)
+		{
+			method: http.MethodPatch, path: "/users/{id}", contentType: user.ContentTypeMergePatch,
+			endpoint: endpoint.Endpoint(endpoints.Patch), decode: decodePatchUser(user.ParseMergePatch),
+			doc: patchUserDoc(user.UpdateRequest{}),
+		},
+		// 🎯 PATCH /users/{id} con Content-Type application/json-patch+json (RFC 6902)
+		{
+			method: http.MethodPatch, path: "/users/{id}", contentType: user.ContentTypeJSONPatch,
+			endpoint: endpoint.Endpoint(endpoints.Patch), decode: decodePatchUser(user.ParseJSONPatch),
+			doc: patchUserDoc([]user.PatchOperation{}),
+		},
+		// 🎯 PATCH /users/{id} - Actualizar usuario (parcial)
+		{
+			method: http.MethodPatch, path: "/users/{id}",
+			endpoint: endpoint.Endpoint(endpoints.Update), decode: decodeUpdateUser,
+			doc: patchUserDoc(user.UpdateRequest{}),
+		},
+		// 🎯 PUT /users/{id} - Reemplazar usuario (lo crea si no existe)
+		{
+			method: http.MethodPut, path: "/users/{id}",
+			endpoint: endpoint.Endpoint(endpoints.Replace), decode: decodeReplaceUser,
+			doc: operation{
+				id: "replaceUser", summary: "Replace a user, or create it with that ID",
+				params: []param{ifMatchParam},
+				body:   user.ReplaceRequest{},
+				responses: []result{
+					{http.StatusOK, "User replaced", userEnvelope{}, versionHeaders},
+					{http.StatusCreated, "User created", userEnvelope{}, versionHeaders},
+				},
+			},
+		},
+		// 🎯 POST /users/{id}/restore - Restaurar un usuario de la papelera
+		{
+			method: http.MethodPost, path: "/users/{id}/restore",
+			endpoint: endpoint.Endpoint(endpoints.Restore), decode: decodeRestoreUser,
+			doc: operation{
+				id: "restoreUser", summary: "Restore a user from the trash",
+				responses: []result{{http.StatusOK, "User restored", userEnvelope{}, versionHeaders}},
+			},
+		},
+		// 🎯 DELETE /users/{id} - Eliminar usuario (?hard=true lo borra definitivamente)
+		{
+			method: http.MethodDelete, path: "/users/{id}",
+			endpoint: endpoint.Endpoint(endpoints.Delete), decode: decodeDeleteUser,
+			doc: operation{
+				id: "deleteUser", summary: "Move a user to the trash, or delete it permanently with hard=true",
+				params:    []param{ifMatchParam, {"hard", inQuery, "Delete the user permanently instead of moving it to the trash", false}},
+				responses: []result{{http.StatusOK, "User deleted", response.SuccessResponse{}, nil}},
+			},
+		},
+		// 🎯 GET /errors - Catálogo de errores (code, type, title y status)
+		{
+			method: http.MethodGet, path: "/errors",
+			endpoint: endpoint.Endpoint(endpoints.Errors), decode: decodeNoRequest,
+			doc: operation{
+				id: "listErrors", summary: "List the errors the API can return",
+				responses: []result{{http.StatusOK, "The error catalog", errorCatalogEnvelope{}, nil}},
+			},
+		},
+	}
+}
+
+// patchUserDoc documenta PATCH /users/{id}; las variantes por Content-Type solo cambian el body
+func patchUserDoc(body interface{}) operation {
+	return operation{
+		id: "patchUser", summary: "Update some fields of a user (JSON, JSON Merge Patch or JSON Patch)",
+		params:    []param{ifMatchParam},
+		body:      body,
+		responses: []result{{http.StatusOK, "User updated", userEnvelope{}, versionHeaders}},
+	}
+}
+
+// 🎯 Decoder para CREATE: decodifica el body JSON
+func decodeStoreUser(_ context.Context, r *http.Request) (interface{}, error) {
+	var req user.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, user.ErrInvalidBody
+	}
+
+	req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	if len(req.IdempotencyKey) > user.MaxIdempotencyKeyLength {
+		return nil, user.NewErrParameter("Idempotency-Key", "must be at most %d characters", user.MaxIdempotencyKeyLength)
+	}
+	return req, nil
+}
+
+// 🎯 Decoder para CREATE masivo: el body es un array de usuarios
+func decodeStoreUsers(_ context.Context, r *http.Request) (interface{}, error) {
+	var req user.CreateBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Items); err != nil {
+		return nil, user.ErrInvalidBody
+	}
+
+	if value := r.URL.Query().Get("atomic"); value != "" {
+		atomic, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, user.NewErrParameter("atomic", "must be true or false")
+		}
+		req.Atomic = atomic
+	}
+	return req, nil
+}
+
+// 🎯 Decoder para GET: extrae el ID de la URL
+func decodeGetUser(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok || id == "" {
+		return nil, user.ErrIDRequired
+	}
+	return user.GetRequest{ID: id, Cache: parseCacheCondition(r)}, nil
+}
+
+// 🎯 Decoder para GET by email: extrae el email de la URL
+func decodeGetUserByEmail(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	email, ok := vars["email"]
+	if !ok || email == "" {
+		return nil, user.ErrEmailRequired
+	}
+	return user.GetByEmailRequest{Email: email}, nil
+}
+
+// 🎯 Decoder para GET ALL: extrae query parameters (limit, page, filters)
+func decodeGetAllUsers(_ context.Context, r *http.Request) (interface{}, error) {
+	// Extraer query parameters
+	query := r.URL.Query()
+
+	// Convertir limit y page a int
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+
+	page := 0
+	if pageStr := query.Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil {
+			page = p
+		}
+	}
+
+	// Orden: sort=last_name,-created_at
+	sort, err := user.ParseSort(query.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Paginación por cursor: basta con enviar el parámetro (vacío en la primera página)
+	var cursor *user.Cursor
+	if token := query.Get("cursor"); token != "" {
+		cursor, err = user.DecodeCursor(token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Construir GetAllRequest con los query parameters
+	req := user.GetAllRequest{
+		IDs:           filters.IDs,
+		FirstName:     filters.FirstName,
+		LastName:      filters.LastName,
+		Email:         filters.Email,
+		Phone:         filters.Phone,
+		Query:         filters.Query,
+		Conditions:    filters.Conditions,
+		CreatedAfter:  filters.CreatedAfter,
+		CreatedBefore: filters.CreatedBefore,
+		UpdatedAfter:  filters.UpdatedAfter,
+		UpdatedBefore: filters.UpdatedBefore,
+		Sort:          sort,
+		Limit:         limit,
+		Page:          page,
+		CursorMode:    query.Has("cursor"),
+		Cursor:        cursor,
+		Cache:         parseCacheCondition(r),
+	}
+
+	return req, nil
+}
+
+// 🎯 Decoder para la papelera: igual que GET /users pero sobre los usuarios borrados
+func decodeGetDeletedUsers(ctx context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeGetAllUsers(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	getAll := req.(user.GetAllRequest)
+	getAll.Deleted = true
+	return getAll, nil
+}
+
+// parseFilters lee los filtros de GET /users; las operaciones masivas usan los mismos
+func parseFilters(query url.Values) (user.Filters, error) {
+	filters := user.Filters{
+		FirstName: query.Get("first_name"),
+		LastName:  query.Get("last_name"),
+		Email:     query.Get("email"),
+		Phone:     query.Get("phone"),
+		Query:     query.Get("q"),
+	}
+
+	// IDs: ids=a,b,c (también se puede repetir el parámetro)
+	for _, value := range query["ids"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				filters.IDs = append(filters.IDs, id)
+			}
+		}
+	}
+
+	// Filtros con operador: email[eq]=, last_name[prefix]=, phone[in]=a,b ...
+	// 💡 Un parámetro repetido suma una condición por valor (se combinan con AND).
+	// Las keys se recorren ordenadas: las condiciones y el primer error no dependen del orden del mapa.
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range query[key] {
+			condition, err := user.ParseCondition(key, value)
+			if err != nil {
+				return filters, err
+			}
+			if condition == nil {
+				break
+			}
+			filters.Conditions = append(filters.Conditions, *condition)
+		}
+	}
+
+	// Rangos de fechas en RFC 3339
+	var err error
+	if filters.CreatedAfter, err = parseTimeParam(query, "created_after"); err != nil {
+		return filters, err
+	}
+	if filters.CreatedBefore, err = parseTimeParam(query, "created_before"); err != nil {
+		return filters, err
+	}
+	if filters.UpdatedAfter, err = parseTimeParam(query, "updated_after"); err != nil {
+		return filters, err
+	}
+	if filters.UpdatedBefore, err = parseTimeParam(query, "updated_before"); err != nil {
+		return filters, err
+	}
+
+	return filters, nil
+}
+
+// parseCacheCondition lee los headers del GET condicional.
+// Un If-Modified-Since inválido se ignora, como indica RFC 9110.
+func parseCacheCondition(r *http.Request) user.CacheCondition {
+	cond := user.CacheCondition{IfNoneMatch: r.Header.Get("If-None-Match")}
+	if value := r.Header.Get("If-Modified-Since"); value != "" {
+		if t, err := http.ParseTime(value); err == nil {
+			cond.IfModifiedSince = &t
+		}
+	}
+	return cond
+}
+
+// parseIfMatch lee el header If-Match de las escrituras sobre /users/{id}.
+// Un ETag que no emitimos nunca coincide con la versión actual: ErrVersionMismatch (412).
+func parseIfMatch(r *http.Request) (*user.Precondition, error) {
+	return user.ParseIfMatch(r.Header.Get("If-Match"))
+}
+
+// parseDryRun lee el flag dry_run de las operaciones masivas
+func parseDryRun(query url.Values) (bool, error) {
+	value := query.Get("dry_run")
+	if value == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, user.NewErrParameter("dry_run", "must be true or false")
+	}
+	return dryRun, nil
+}
+
+// parseTimeParam lee un parámetro de fecha opcional en formato RFC 3339
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	value := query.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, user.NewErrParameter(name, "must be an RFC 3339 timestamp, e.g. 2024-01-31T15:04:05Z")
+	}
+	return &t, nil
+}
+
+// 🎯 Decoder para UPDATE: extrae ID de la URL y body JSON
+func decodeUpdateUser(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok || id == "" {
+		return nil, user.ErrIDRequired
+	}
+
+	var req user.UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, user.ErrInvalidBody
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Asignar el ID extraído de la URL
+	req.ID = id
+	req.IfMatch = ifMatch
+	return req, nil
+}
+
+// 🎯 Decoder para PATCH con JSON Patch o Merge Patch: parse convierte el body en operaciones
+func decodePatchUser(parse func([]byte) ([]user.PatchOperation, error)) httptransport.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		vars := mux.Vars(r)
+		id, ok := vars["id"]
+		if !ok || id == "" {
+			return nil, user.ErrIDRequired
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, user.ErrInvalidBody
+		}
+		ops, err := parse(body)
+		if err != nil {
+			return nil, err
+		}
+
+		ifMatch, err := parseIfMatch(r)
+		if err != nil {
+			return nil, err
+		}
+		return user.PatchRequest{ID: id, IfMatch: ifMatch, Operations: ops}, nil
+	}
+}
+
+// contentTypeRegexp matchea el media type sin importar mayúsculas ni parámetros (ej: charset)
+func contentTypeRegexp(mediaType string) string {
+	return `(?i)^` + regexp.QuoteMeta(mediaType) + `\s*(;|$)`
+}
+
+// 🎯 Decoder para PUT: el ID de la URL manda sobre el del body
+func decodeReplaceUser(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok || id == "" {
+		return nil, user.ErrIDRequired
+	}
+
+	var req user.ReplaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, user.ErrInvalidBody
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ID = id
+	req.IfMatch = ifMatch
+	return req, nil
+}
+
+// 🎯 Decoder para UPDATE masivo: selección en el query string, campos en el body
+func decodeUpdateUsers(_ context.Context, r *http.Request) (interface{}, error) {
+	var req user.UpdateBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, user.ErrInvalidBody
+	}
+
+	query := r.URL.Query()
+	filters, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	dryRun, err := parseDryRun(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Filters = filters
+	req.DryRun = dryRun
+	return req, nil
+}
+
+// 🎯 Decoder para DELETE masivo: selección en el query string
+func decodeDeleteUsers(_ context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	filters, err := parseFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	dryRun, err := parseDryRun(query)
+	if err != nil {
+		return nil, err
+	}
+	return user.DeleteBatchRequest{Filters: filters, DryRun: dryRun}, nil
+}
+
+// 🎯 Decoder para DELETE: extrae el ID de la URL
+func decodeDeleteUser(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok || id == "" {
+		return nil, user.ErrIDRequired
+	}
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		return nil, err
+	}
+	hard := false
+	if value := r.URL.Query().Get("hard"); value != "" {
+		hard, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, user.NewErrParameter("hard", "must be true or false")
+		}
+	}
+	return user.DeleteRequest{ID: id, IfMatch: ifMatch, Hard: hard}, nil
+}
+
+// 🎯 Decoder para los endpoints que no leen nada del request
+func decodeNoRequest(_ context.Context, _ *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// 🎯 Decoder para RESTORE: extrae el ID de la URL
+func decodeRestoreUser(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok || id == "" {
+		return nil, user.ErrIDRequired
+	}
+	return user.RestoreRequest{ID: id}, nil
+}
+
+// 🎯 Encoder para todas las respuestas
+func encodeResponse(ctx context.Context, w http.ResponseWriter, resp interface{}) error {
+	respObj := user.LocalizeResponse(resp.(response.Response), user.LanguageFromContext(ctx))
+
+	// Headers propios de la respuesta (ej: ETag)
+	if headerer, ok := resp.(httptransport.Headerer); ok {
+		for key, values := range headerer.Headers() {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+	}
+
+	// 304 no lleva body: el cliente reutiliza la representación que ya tiene
+	if respObj.StatusCode() == http.StatusNotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(respObj.StatusCode())
+	return json.NewEncoder(w).Encode(respObj)
+}
+
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	// Headers propios del error (ej: Idempotent-Replayed)
+	if headerer, ok := err.(httptransport.Headerer); ok {
+		for key, values := range headerer.Headers() {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+	}
+
+	// 🔍 Todo error sale como problem+json (RFC 9457)
+	problem, ok := user.NewProblem(err)
+	if !ok {
+		// ❌ Si no es un error conocido, es un error estándar de Go
+		// 💡 Lo convertimos a INTERNAL_ERROR sin exponer su texto
+		problem = user.InternalProblem("unexpected error")
+	}
+	problem = problem.Localize(user.LanguageFromContext(ctx))
+	problem.Instance = instanceFromContext(ctx)
+	problem.RequestID = requestIDFromContext(ctx)
+
+	w.Header().Set("Content-Type", user.ContentTypeProblem)
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
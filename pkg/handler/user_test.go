@@ -0,0 +1,1014 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NicoJCastro/gocourse_user/internal/user"
+	"github.com/NicoJCastro/gocourse_user/pkg/handler"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	logger := log.New(io.Discard, "", 0)
+	service := user.NewService(logger, user.NewMemoryRepository(logger), user.ServiceConfig{
+		DefaultCountry: "AR",
+		EmailPolicy:    user.EmailPolicyProvider,
+	})
+	endpoints := user.MakeEndpoints(service, user.Config{LimPageDef: "10"})
+
+	srv := httptest.NewServer(handler.NewUserHTTPServer(context.Background(), endpoints))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do ejecuta la request y decodifica el body JSON en un mapa
+func do(t *testing.T, method, url, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil && err != io.EOF {
+		t.Fatalf("decoding body: %v", err)
+	}
+	return resp, decoded
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := do(t, http.MethodPost, srv.URL+"/users",
+		`{"first_name":"Ana","last_name":"Gomez","email":" Ana.Gomez+news@GMail.com ","phone":"(011) 4555-1234"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+	}
+	created := body["data"].(map[string]interface{})
+	if created["email"] != "anagomez@gmail.com" {
+		t.Fatalf("stored email = %v, want anagomez@gmail.com", created["email"])
+	}
+
+	for _, email := range []string{"anagomez@gmail.com", "Ana.Gomez@gmail.com", "ana.gomez+other@googlemail.com"} {
+		resp, body = do(t, http.MethodGet, srv.URL+"/users/by-email/"+email, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET by email %q: status = %d, body %v", email, resp.StatusCode, body)
+		}
+		if got := body["data"].(map[string]interface{})["id"]; got != created["id"] {
+			t.Fatalf("GET by email %q returned user %v, want %v", email, got, created["id"])
+		}
+	}
+
+	resp, body = do(t, http.MethodGet, srv.URL+"/users/by-email/gomez@gmail.com", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET unknown email: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestGetAllUsersSort(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, u := range []string{
+		`{"first_name":"Carla","last_name":"Gomez","email":"carla@example.com","phone":"+541145550001"}`,
+		`{"first_name":"Ana","last_name":"Diaz","email":"ana@example.com","phone":"+541145550002"}`,
+		`{"first_name":"Bruno","last_name":"Perez","email":"bruno@example.com","phone":"+541145550003"}`,
+	} {
+		if resp, body := do(t, http.MethodPost, srv.URL+"/users", u); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+		}
+	}
+
+	resp, body := do(t, http.MethodGet, srv.URL+"/users?sort=first_name", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body %v", resp.StatusCode, body)
+	}
+	var names []string
+	for _, u := range body["data"].([]interface{}) {
+		names = append(names, u.(map[string]interface{})["first_name"].(string))
+	}
+	if strings.Join(names, ",") != "Ana,Bruno,Carla" {
+		t.Fatalf("sorted names = %v, want Ana,Bruno,Carla", names)
+	}
+
+	for _, sort := range []string{"password", "first_name,,last_name"} {
+		resp, body = do(t, http.MethodGet, srv.URL+"/users?sort="+sort, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("sort=%s: status = %d, body %v", sort, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestGetAllUsersCursor(t *testing.T) {
+	srv := newTestServer(t)
+
+	for i := 0; i < 5; i++ {
+		body := fmt.Sprintf(`{"first_name":"User","last_name":"N%d","email":"user%d@example.com","phone":"+54114555000%d"}`, i, i, i)
+		if resp, b := do(t, http.MethodPost, srv.URL+"/users", body); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, b)
+		}
+	}
+
+	seen := map[string]bool{}
+	url := srv.URL + "/users?limit=2&cursor="
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor pagination did not finish")
+		}
+		resp, body := do(t, http.MethodGet, url, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, body %v", resp.StatusCode, body)
+		}
+		for _, u := range body["data"].([]interface{}) {
+			id := u.(map[string]interface{})["id"].(string)
+			if seen[id] {
+				t.Fatalf("user %s returned twice", id)
+			}
+			seen[id] = true
+		}
+		next, _ := body["meta"].(map[string]interface{})["next_cursor"].(string)
+		if next == "" {
+			break
+		}
+		url = srv.URL + "/users?limit=2&cursor=" + next
+	}
+	if len(seen) != 5 {
+		t.Fatalf("saw %d users, want 5", len(seen))
+	}
+
+	for _, query := range []string{"cursor=garbage", "cursor=&sort=first_name"} {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?"+query, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, body %v", query, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestGetAllUsersFilterOperators(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, u := range []string{
+		`{"first_name":"Bob","last_name":"Gomez","email":"bob@x.com","phone":"+541145550001"}`,
+		`{"first_name":"Bobby","last_name":"Diaz","email":"bob@x.com.ar","phone":"(011) 4555-0002"}`,
+	} {
+		if resp, body := do(t, http.MethodPost, srv.URL+"/users", u); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"email=bob@x.com", 2},
+		{"email[eq]=bob@x.com", 1},
+		{"first_name[prefix]=bobb", 1},
+		{"last_name[contains]=OME", 1},
+		{"phone[in]=%2B541145550001,011%204555-0002", 2},
+		{"first_name[eq]=bob&last_name=diaz", 0},
+		// Un operador repetido suma cada valor como otra condición
+		{"email[contains]=bob&email[contains]=.ar", 1},
+		{"email[contains]=.ar&email[contains]=bob", 1},
+		{"first_name[prefix]=bob&first_name[prefix]=x", 0},
+	}
+
+	for _, tt := range tests {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?"+tt.query, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %v", tt.query, resp.StatusCode, body)
+		}
+		if got := len(body["data"].([]interface{})); got != tt.want {
+			t.Errorf("%s: got %d users, want %d", tt.query, got, tt.want)
+		}
+		if total := body["meta"].(map[string]interface{})["total_count"].(float64); int(total) != tt.want {
+			t.Errorf("%s: total_count = %v, want %d", tt.query, total, tt.want)
+		}
+	}
+
+	for _, query := range []string{"email[like]=bob", "password[eq]=x", "email[eq]=", "email[eq]=bob@x.com&email[eq]="} {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?"+query, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, body %v", query, resp.StatusCode, body)
+		}
+	}
+
+	// 🔧 Con varios parámetros inválidos el error reportado es siempre el mismo
+	for i := 0; i < 20; i++ {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?phone[like]=1&email[like]=bob", "")
+		if detail, _ := body["detail"].(string); resp.StatusCode != http.StatusBadRequest || !strings.Contains(detail, "email[like]") {
+			t.Fatalf("several invalid filters: status = %d, detail %q", resp.StatusCode, detail)
+		}
+	}
+}
+
+func TestGetAllUsersDateRanges(t *testing.T) {
+	srv := newTestServer(t)
+
+	before := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if resp, body := do(t, http.MethodPost, srv.URL+"/users",
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145550001"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+	}
+	after := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"created_after=" + before, 1},
+		{"created_before=" + before, 0},
+		{"updated_after=" + before + "&updated_before=" + after, 1},
+		{"updated_after=" + after, 0},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?"+tt.query, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %v", tt.query, resp.StatusCode, body)
+		}
+		if got := len(body["data"].([]interface{})); got != tt.want {
+			t.Errorf("%s: got %d users, want %d", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"created_after=yesterday", "updated_before=2024-01-31", "created_before=2024-01-31T15:04:05"} {
+		resp, body := do(t, http.MethodGet, srv.URL+"/users?"+query, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, body %v", query, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestGetAllUsersSearch(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, u := range []string{
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"(011) 4555-1234"}`,
+		`{"first_name":"Anabel","last_name":"Gomez","email":"anabel@example.com","phone":"+541145550002"}`,
+		`{"first_name":"Carla","last_name":"Ruiz","email":"carla@example.com","phone":"+541145550003"}`,
+	} {
+		if resp, body := do(t, http.MethodPost, srv.URL+"/users", u); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+		}
+	}
+
+	resp, body := do(t, http.MethodGet, srv.URL+"/users?q=ana+gomez", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body %v", resp.StatusCode, body)
+	}
+	var names []string
+	for _, u := range body["data"].([]interface{}) {
+		names = append(names, u.(map[string]interface{})["first_name"].(string))
+	}
+	if strings.Join(names, ",") != "Ana,Anabel" {
+		t.Fatalf("results = %v, want Ana,Anabel", names)
+	}
+	if total := body["meta"].(map[string]interface{})["total_count"].(float64); total != 2 {
+		t.Fatalf("total_count = %v, want 2", total)
+	}
+
+	if resp, body = do(t, http.MethodGet, srv.URL+"/users?q=ana&cursor=", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("q with cursor: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestCreateUsersBatch(t *testing.T) {
+	srv := newTestServer(t)
+
+	batch := `[
+		{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"},
+		{"first_name":"Bruno","last_name":"Diaz","email":"not-an-email","phone":"+541145559876"},
+		{"first_name":"Carla","last_name":"Gomez","email":"carla@example.com","phone":"+541145550001"}
+	]`
+
+	// 🎯 Atomic: el error de un usuario aborta el lote entero
+	resp, body := do(t, http.MethodPost, srv.URL+"/users/batch?atomic=true", batch)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("atomic status = %d, want 400, body %v", resp.StatusCode, body)
+	}
+	items := body["data"].([]interface{})
+	for i, want := range []float64{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency} {
+		if got := items[i].(map[string]interface{})["status"]; got != want {
+			t.Errorf("atomic item %d status = %v, want %v", i, got, want)
+		}
+	}
+	_, body = do(t, http.MethodGet, srv.URL+"/users", "")
+	if total := body["meta"].(map[string]interface{})["total_count"]; total != float64(0) {
+		t.Fatalf("total_count after aborted batch = %v, want 0", total)
+	}
+
+	// Best-effort: 207 con el resultado de cada usuario
+	resp, body = do(t, http.MethodPost, srv.URL+"/users/batch", batch)
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("best-effort status = %d, want 207, body %v", resp.StatusCode, body)
+	}
+	items = body["data"].([]interface{})
+	created := items[0].(map[string]interface{})
+	if created["status"] != float64(http.StatusCreated) || created["data"].(map[string]interface{})["id"] == "" {
+		t.Fatalf("best-effort item 0 = %v", created)
+	}
+	failed := items[1].(map[string]interface{})
+	errBody := failed["error"].(map[string]interface{})
+	if _, ok := errBody["errors"].(map[string]interface{})["email"]; !ok {
+		t.Fatalf("best-effort item 1 error = %v, want an email validation error", errBody)
+	}
+	summary := body["meta"].(map[string]interface{})
+	if summary["succeeded"] != float64(2) || summary["failed"] != float64(1) {
+		t.Fatalf("summary = %v, want 2 succeeded and 1 failed", summary)
+	}
+
+	resp, body = do(t, http.MethodPost, srv.URL+"/users/batch", `{"first_name":"Ana"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("non-array body: status = %d, body %v", resp.StatusCode, body)
+	}
+	resp, body = do(t, http.MethodPost, srv.URL+"/users/batch", `[]`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("empty batch: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestUsersBatchUpdateAndDelete(t *testing.T) {
+	srv := newTestServer(t)
+
+	var ids []string
+	for _, u := range []string{
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145550001"}`,
+		`{"first_name":"Bruno","last_name":"Diaz","email":"bruno@example.org","phone":"+541145550002"}`,
+		`{"first_name":"Carla","last_name":"Gomez","email":"carla@example.com","phone":"+541145550003"}`,
+	} {
+		resp, body := do(t, http.MethodPost, srv.URL+"/users", u)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+		}
+		ids = append(ids, body["data"].(map[string]interface{})["id"].(string))
+	}
+
+	counts := func(body map[string]interface{}) (interface{}, interface{}) {
+		data := body["data"].(map[string]interface{})
+		return data["matched"], data["affected"]
+	}
+
+	// 🎯 Dry run con los mismos filtros que GET /users
+	resp, body := do(t, http.MethodPatch, srv.URL+"/users/batch?last_name[eq]=gomez&dry_run=true", `{"last_name":"Gómez"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("dry run status = %d, body %v", resp.StatusCode, body)
+	}
+	if matched, affected := counts(body); matched != float64(2) || affected != float64(2) {
+		t.Fatalf("dry run matched %v, affected %v, want 2 and 2", matched, affected)
+	}
+	_, body = do(t, http.MethodGet, srv.URL+"/users?last_name[eq]=gomez", "")
+	if total := body["meta"].(map[string]interface{})["total_count"]; total != float64(2) {
+		t.Fatalf("dry run modified users: total_count = %v", total)
+	}
+
+	resp, body = do(t, http.MethodPatch, srv.URL+"/users/batch?ids="+ids[0]+","+ids[1], `{"phone":"011 4555-0001"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("update status = %d, body %v", resp.StatusCode, body)
+	}
+	if matched, affected := counts(body); matched != float64(2) || affected != float64(1) {
+		t.Fatalf("update matched %v, affected %v, want 2 and 1", matched, affected)
+	}
+
+	resp, body = do(t, http.MethodPatch, srv.URL+"/users/batch?ids="+ids[0], `{"email":"x@example.com"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bulk email update: status = %d, body %v", resp.StatusCode, body)
+	}
+
+	// Sin ids ni filtros no se permite tocar a todos los usuarios
+	resp, body = do(t, http.MethodDelete, srv.URL+"/users/batch", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("delete without selection: status = %d, body %v", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, http.MethodDelete, srv.URL+"/users/batch?email=example.com", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("delete status = %d, body %v", resp.StatusCode, body)
+	}
+	if matched, affected := counts(body); matched != float64(2) || affected != float64(2) {
+		t.Fatalf("delete matched %v, affected %v, want 2 and 2", matched, affected)
+	}
+	_, body = do(t, http.MethodGet, srv.URL+"/users", "")
+	if total := body["meta"].(map[string]interface{})["total_count"]; total != float64(1) {
+		t.Fatalf("total_count after delete = %v, want 1", total)
+	}
+
+	// /users/batch no se confunde con /users/{id}
+	resp, body = do(t, http.MethodGet, srv.URL+"/users/"+ids[1], "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET remaining user: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestReplaceUser(t *testing.T) {
+	srv := newTestServer(t)
+	url := srv.URL + "/users/0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"
+
+	resp, body := do(t, http.MethodPut, url, `{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT new user: status = %d, body %v", resp.StatusCode, body)
+	}
+
+	resp, body = do(t, http.MethodPut, url, `{"first_name":"Ana","last_name":"Perez","email":"ana@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT existing user: status = %d, body %v", resp.StatusCode, body)
+	}
+	if got := body["data"].(map[string]interface{})["last_name"]; got != "Perez" {
+		t.Fatalf("PUT existing user: last_name = %v, want Perez", got)
+	}
+
+	// 🔍 A diferencia de PATCH, los campos que faltan son un error
+	resp, body = do(t, http.MethodPut, url, `{"first_name":"Ana"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("PUT partial user: status = %d, body %v", resp.StatusCode, body)
+	}
+	errs := body["errors"].(map[string]interface{})
+	for _, field := range []string{"last_name", "email", "phone"} {
+		if _, ok := errs[field]; !ok {
+			t.Errorf("PUT partial user: missing error for %s in %v", field, errs)
+		}
+	}
+
+	// El email sigue siendo único
+	do(t, http.MethodPost, srv.URL+"/users", `{"first_name":"Bruno","last_name":"Diaz","email":"bruno@example.com","phone":"+541145550000"}`)
+	resp, body = do(t, http.MethodPut, url, `{"first_name":"Ana","last_name":"Perez","email":"bruno@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("PUT with taken email: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestPatchUserFormats(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := do(t, http.MethodPost, srv.URL+"/users",
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+	}
+	url := srv.URL + "/users/" + body["data"].(map[string]interface{})["id"].(string)
+
+	patch := func(contentType, body string) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPatch, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("building request: %v", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PATCH: %v", err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		return resp, decoded
+	}
+
+	// 🎯 Merge Patch: los miembros enviados se reemplazan, null borra (y un campo obligatorio no se puede borrar)
+	resp, body = patch("application/merge-patch+json; charset=utf-8", `{"last_name":"Perez","phone":"011 4555-9876"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("merge patch status = %d, body %v", resp.StatusCode, body)
+	}
+	data := body["data"].(map[string]interface{})
+	if data["last_name"] != "Perez" || data["phone"] != "+541145559876" || data["first_name"] != "Ana" {
+		t.Fatalf("merge patch result = %v", data)
+	}
+	resp, body = patch("application/merge-patch+json", `{"phone":null}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("merge patch null: status = %d, body %v", resp.StatusCode, body)
+	}
+
+	// JSON Patch: si falla el test no se aplica ninguna operación
+	resp, body = patch("application/json-patch+json",
+		`[{"op":"replace","path":"/first_name","value":"Eva"},{"op":"test","path":"/last_name","value":"Gomez"}]`)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("json patch failed test: status = %d, body %v", resp.StatusCode, body)
+	}
+	resp, body = patch("application/json-patch+json",
+		`[{"op":"test","path":"/last_name","value":"Perez"},{"op":"replace","path":"/first_name","value":"Eva"}]`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("json patch status = %d, body %v", resp.StatusCode, body)
+	}
+	if got := body["data"].(map[string]interface{})["first_name"]; got != "Eva" {
+		t.Fatalf("json patch first_name = %v, want Eva", got)
+	}
+
+	resp, body = patch("application/json-patch+json", `[{"op":"replace","path":"/id","value":"x"}]`)
+	if resp.StatusCode != http.StatusBadRequest || body["field"] != "id" {
+		t.Fatalf("json patch on id: status = %d, body %v", resp.StatusCode, body)
+	}
+
+	// application/json sigue funcionando como antes
+	resp, body = patch("application/json", `{"first_name":"Ana"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("json status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestUserETagAndIfMatch(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := do(t, http.MethodPost, srv.URL+"/users",
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+	}
+	url := srv.URL + "/users/" + body["data"].(map[string]interface{})["id"].(string)
+
+	resp, _ = do(t, http.MethodGet, url, "")
+	etag := resp.Header.Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("GET ETag = %q, want a strong ETag", etag)
+	}
+
+	send := func(method, ifMatch, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("building request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// 🎯 El primer PATCH con el ETag vigente gana; el segundo con el mismo ETag recibe 412
+	first := send(http.MethodPatch, etag, `{"first_name":"Eva"}`)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH with current ETag: status = %d", first.StatusCode)
+	}
+	newETag := first.Header.Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Fatalf("PATCH ETag = %q, want a new version (previous %q)", newETag, etag)
+	}
+	if resp := send(http.MethodPatch, etag, `{"first_name":"Luz"}`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH with stale ETag: status = %d", resp.StatusCode)
+	}
+	if resp := send(http.MethodPut, etag, `{"first_name":"Luz","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PUT with stale ETag: status = %d", resp.StatusCode)
+	}
+	if resp := send(http.MethodDelete, `"garbage"`, ""); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("DELETE with unknown ETag: status = %d", resp.StatusCode)
+	}
+	if resp := send(http.MethodDelete, newETag, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE with current ETag: status = %d", resp.StatusCode)
+	}
+
+	// If-Match en un PUT evita el upsert
+	missing := srv.URL + "/users/0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"
+	req, _ := http.NewRequest(http.MethodPut, missing, strings.NewReader(`{"first_name":"Luz","last_name":"Gomez","email":"luz@example.com","phone":"+541145551234"}`))
+	req.Header.Set("If-Match", "*")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("PUT If-Match on missing user: status = %d", resp.StatusCode)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := do(t, http.MethodPost, srv.URL+"/users",
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, body %v", resp.StatusCode, body)
+	}
+	url := srv.URL + "/users/" + body["data"].(map[string]interface{})["id"].(string)
+
+	get := func(url, header, value string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("building request: %v", err)
+		}
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	first := get(url, "", "")
+	etag, lastModified := first.Header.Get("ETag"), first.Header.Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("GET validators: ETag %q, Last-Modified %q", etag, lastModified)
+	}
+
+	// 🎯 Sin cambios: 304 sin body y con los mismos validadores
+	resp = get(url, "If-None-Match", etag)
+	if resp.StatusCode != http.StatusNotModified || resp.ContentLength > 0 {
+		t.Fatalf("If-None-Match current: status = %d, length %d", resp.StatusCode, resp.ContentLength)
+	}
+	if resp.Header.Get("ETag") != etag {
+		t.Fatalf("304 ETag = %q, want %q", resp.Header.Get("ETag"), etag)
+	}
+	if resp := get(url, "If-Modified-Since", lastModified); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since current: status = %d", resp.StatusCode)
+	}
+	if resp := get(url, "If-Modified-Since", "not a date"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("invalid If-Modified-Since: status = %d", resp.StatusCode)
+	}
+
+	// 🌐 El body en español es otra representación: el ETag en inglés no alcanza para un 304
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Accept-Language", "es")
+	req.Header.Set("If-None-Match", etag)
+	spanish, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	spanish.Body.Close()
+	if spanish.StatusCode != http.StatusOK || spanish.Header.Get("ETag") == etag {
+		t.Fatalf("If-None-Match in another language: status = %d, ETag %q", spanish.StatusCode, spanish.Header.Get("ETag"))
+	}
+
+	list := get(srv.URL+"/users", "", "")
+	listETag := list.Header.Get("ETag")
+	if !strings.HasPrefix(listETag, `W/"1-`) {
+		t.Fatalf("GET /users ETag = %q, want a weak ETag with the count", listETag)
+	}
+	if resp := get(srv.URL+"/users", "If-None-Match", listETag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("GET /users If-None-Match current: status = %d", resp.StatusCode)
+	}
+	if resp := get(srv.URL+"/users?first_name=Bruno", "If-None-Match", listETag); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /users with other filters: status = %d", resp.StatusCode)
+	}
+	if resp := get(srv.URL+"/users?cursor", "If-None-Match", listETag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("GET /users?cursor If-None-Match current: status = %d", resp.StatusCode)
+	}
+	// Una request inválida responde el error aunque el ETag coincida
+	if resp := get(srv.URL+"/users?cursor&sort=first_name", "If-None-Match", listETag); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("GET /users?cursor&sort If-None-Match current: status = %d", resp.StatusCode)
+	}
+
+	// Una modificación invalida tanto el usuario como el listado
+	if resp, body := do(t, http.MethodPatch, url, `{"first_name":"Eva"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body %v", resp.StatusCode, body)
+	}
+	resp = get(url, "If-None-Match", etag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Fatalf("GET after PATCH: status = %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	resp = get(srv.URL+"/users", "If-None-Match", listETag)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /users after PATCH: status = %d", resp.StatusCode)
+	}
+	listETag = resp.Header.Get("ETag")
+
+	// El borrado cambia la cantidad
+	if resp, body := do(t, http.MethodDelete, url, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE status = %d, body %v", resp.StatusCode, body)
+	}
+	resp = get(srv.URL+"/users", "If-None-Match", listETag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `W/"0-0-en"` {
+		t.Fatalf("GET /users after DELETE: status = %d, ETag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+}
+
+func TestUserTrash(t *testing.T) {
+	srv := newTestServer(t)
+
+	ids := make([]string, 0, 2)
+	for _, body := range []string{
+		`{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`,
+		`{"first_name":"Bruno","last_name":"Diaz","email":"bruno@example.com","phone":"+541145559876"}`,
+	} {
+		resp, decoded := do(t, http.MethodPost, srv.URL+"/users", body)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create status = %d, body %v", resp.StatusCode, decoded)
+		}
+		ids = append(ids, decoded["data"].(map[string]interface{})["id"].(string))
+	}
+	ana, bruno := srv.URL+"/users/"+ids[0], srv.URL+"/users/"+ids[1]
+
+	for _, url := range []string{ana, bruno} {
+		if resp, body := do(t, http.MethodDelete, url, ""); resp.StatusCode != http.StatusOK {
+			t.Fatalf("DELETE status = %d, body %v", resp.StatusCode, body)
+		}
+	}
+
+	resp, body := do(t, http.MethodGet, srv.URL+"/users/deleted?first_name=ana", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /users/deleted status = %d, body %v", resp.StatusCode, body)
+	}
+	if data := body["data"].([]interface{}); len(data) != 1 || data[0].(map[string]interface{})["id"] != ids[0] {
+		t.Fatalf("GET /users/deleted?first_name=ana data = %v", data)
+	}
+	if total := body["meta"].(map[string]interface{})["total_count"]; total != float64(1) {
+		t.Fatalf("GET /users/deleted total_count = %v, want 1", total)
+	}
+
+	// 🎯 Restaurar lo devuelve al listado con un ETag nuevo
+	resp, body = do(t, http.MethodPost, ana+"/restore", "")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+		t.Fatalf("restore status = %d, ETag %q, body %v", resp.StatusCode, resp.Header.Get("ETag"), body)
+	}
+	if resp, _ := do(t, http.MethodGet, ana, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET after restore status = %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, ana+"/restore", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("restore active user status = %d, want 409", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodPost, srv.URL+"/users/0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f/restore", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("restore missing user status = %d, want 404", resp.StatusCode)
+	}
+
+	// Borrado definitivo: desde la papelera ya no se puede restaurar
+	if resp, _ := do(t, http.MethodDelete, bruno+"?hard=maybe", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("DELETE ?hard=maybe status = %d, want 400", resp.StatusCode)
+	}
+	if resp, body := do(t, http.MethodDelete, bruno+"?hard=true", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("hard DELETE status = %d, body %v", resp.StatusCode, body)
+	}
+	if resp, _ := do(t, http.MethodPost, bruno+"/restore", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("restore purged user status = %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodDelete, bruno+"?hard=true", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("hard DELETE twice status = %d, want 404", resp.StatusCode)
+	}
+
+	resp, body = do(t, http.MethodGet, srv.URL+"/users/deleted", "")
+	if resp.StatusCode != http.StatusOK || len(body["data"].([]interface{})) != 0 {
+		t.Fatalf("GET /users/deleted after purge: status = %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	srv := newTestServer(t)
+
+	post := func(key, body string) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/users", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("building request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST /users: %v", err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		return resp, decoded
+	}
+	ana := `{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`
+
+	// 🎯 Reintentos concurrentes con la misma key crean un solo usuario
+	const n = 5
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, body := post("retry-1", ana)
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("POST %d status = %d, body %v", i, resp.StatusCode, body)
+				return
+			}
+			ids[i] = body["data"].(map[string]interface{})["id"].(string)
+		}(i)
+	}
+	wg.Wait()
+	for i := 1; i < n; i++ {
+		if ids[i] != ids[0] {
+			t.Fatalf("retries got different users: %v", ids)
+		}
+	}
+
+	// El reintento posterior repite la respuesta y lo indica en un header
+	resp, body := post("retry-1", `{"phone":"+541145551234","email":"ana@example.com","last_name":"Gomez","first_name":"Ana"}`)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay status = %d, Idempotent-Replayed %q", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+	if id := body["data"].(map[string]interface{})["id"]; id != ids[0] {
+		t.Fatalf("replay id = %v, want %s", id, ids[0])
+	}
+
+	// La misma key con otro body es un error del cliente
+	resp, body = post("retry-1", `{"first_name":"Eva","last_name":"Gomez","email":"eva@example.com","phone":"+541145551234"}`)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key status = %d, body %v", resp.StatusCode, body)
+	}
+
+	// Los errores del cliente también se repiten, marcados como tales
+	resp, _ = post("retry-2", ana)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate email status = %d, want 409", resp.StatusCode)
+	}
+	resp, _ = post("retry-2", ana)
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replayed conflict status = %d, Idempotent-Replayed %q", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+
+	resp, body = do(t, http.MethodGet, srv.URL+"/users", "")
+	if total := body["meta"].(map[string]interface{})["total_count"]; resp.StatusCode != http.StatusOK || total != float64(1) {
+		t.Fatalf("GET /users total_count = %v, want 1", total)
+	}
+
+	if resp, _ := post(strings.Repeat("k", 256), ana); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("long key status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	srv := newTestServer(t)
+
+	// 🎯 Un usuario inexistente: 404 problem+json con type, instance y request_id
+	missing := "/users/0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"
+	resp, body := do(t, http.MethodGet, srv.URL+missing, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET missing user status = %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != user.ContentTypeProblem {
+		t.Fatalf("Content-Type = %q, want %q", ct, user.ContentTypeProblem)
+	}
+	requestID := resp.Header.Get(handler.RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("missing X-Request-ID header")
+	}
+	want := map[string]interface{}{
+		"type":       user.ProblemTypeBase + "user-not-found",
+		"title":      "User not found",
+		"status":     float64(http.StatusNotFound),
+		"detail":     "user with ID 0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f not found",
+		"instance":   missing,
+		"request_id": requestID,
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %v, want %v", key, body[key], value)
+		}
+	}
+
+	// El X-Request-ID del cliente se respeta
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+missing, nil)
+	req.Header.Set(handler.RequestIDHeader, "trace-123")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var problem map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(handler.RequestIDHeader) != "trace-123" || problem["request_id"] != "trace-123" {
+		t.Fatalf("request id: header %q, body %v", resp.Header.Get(handler.RequestIDHeader), problem["request_id"])
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		slug, code         string
+	}{
+		// Errores de los decoders que antes eran 500
+		{http.MethodPost, "/users", `{"first_name":`, http.StatusBadRequest, "invalid-body", "INVALID_BODY"},
+		{http.MethodPatch, "/users/" + "0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f", `[`, http.StatusBadRequest, "invalid-body", "INVALID_BODY"},
+		{http.MethodGet, "/users?sort=password", "", http.StatusBadRequest, "invalid-sort", "INVALID_SORT"},
+		{http.MethodGet, "/users?cursor=abc", "", http.StatusBadRequest, "invalid-cursor", "INVALID_CURSOR"},
+		{http.MethodGet, "/users?email[like]=x", "", http.StatusBadRequest, "invalid-filter", "INVALID_FILTER"},
+		{http.MethodDelete, "/users/batch", "", http.StatusBadRequest, "selection-required", "SELECTION_REQUIRED"},
+		{http.MethodPost, "/users", `{"first_name":"","last_name":"Gomez","email":"bad","phone":"1"}`, http.StatusBadRequest, "validation-failed", "VALIDATION_FAILED"},
+		{http.MethodDelete, "/users/batch?first_name=a&dry_run=maybe", "", http.StatusBadRequest, "invalid-parameter", "INVALID_PARAMETER"},
+		{http.MethodPost, "/users/batch", `{}`, http.StatusBadRequest, "invalid-body", "INVALID_BODY"},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, tt.method, srv.URL+tt.path, tt.body)
+		if resp.StatusCode != tt.status || body["type"] != user.ProblemTypeBase+tt.slug || body["code"] != tt.code {
+			t.Errorf("%s %s: status %d, type %v, code %v; want %d, %s, %s", tt.method, tt.path, resp.StatusCode, body["type"], body["code"], tt.status, tt.slug, tt.code)
+		}
+	}
+
+	// Un email repetido tiene su propio código
+	payload := `{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`
+	do(t, http.MethodPost, srv.URL+"/users", payload)
+	resp, body = do(t, http.MethodPost, srv.URL+"/users", payload)
+	if resp.StatusCode != http.StatusConflict || body["code"] != "USER_EMAIL_TAKEN" || body["field"] != "email" {
+		t.Fatalf("duplicated email: status %d, body %v", resp.StatusCode, body)
+	}
+}
+
+func TestErrorCatalogEndpoint(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, body := do(t, http.MethodGet, srv.URL+"/errors", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /errors: status %d, body %v", resp.StatusCode, body)
+	}
+	entries, _ := body["data"].([]interface{})
+	if len(entries) != len(user.ErrorCatalog(user.DefaultLanguage)) {
+		t.Fatalf("GET /errors returned %d entries, want %d", len(entries), len(user.ErrorCatalog(user.DefaultLanguage)))
+	}
+	codes := make(map[string]bool)
+	for _, entry := range entries {
+		codes[entry.(map[string]interface{})["code"].(string)] = true
+	}
+	for _, code := range []string{"USER_NOT_FOUND", "USER_EMAIL_TAKEN", "INTERNAL_ERROR"} {
+		if !codes[code] {
+			t.Errorf("%s is not in GET /errors", code)
+		}
+	}
+}
+
+func TestLocalizedResponses(t *testing.T) {
+	srv := newTestServer(t)
+
+	send := func(method, path, acceptLanguage, body string) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("building request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", acceptLanguage)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("decoding body: %v", err)
+		}
+		return resp, decoded
+	}
+
+	payload := `{"first_name":"Ana","last_name":"Gomez","email":"ana@example.com","phone":"+541145551234"}`
+	resp, body := send(http.MethodPost, "/users", "es-AR,es;q=0.9,en;q=0.8", payload)
+	if resp.StatusCode != http.StatusCreated || body["message"] != "Usuario creado correctamente" {
+		t.Fatalf("create in Spanish: status %d, body %v", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Language") != "es" || resp.Header.Get("Vary") != "Accept-Language" {
+		t.Fatalf("headers: Content-Language %q, Vary %q", resp.Header.Get("Content-Language"), resp.Header.Get("Vary"))
+	}
+
+	// Los errores se traducen; code y type no cambian
+	const missing = "/users/0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f"
+	resp, body = send(http.MethodGet, missing, "es", "")
+	if resp.StatusCode != http.StatusNotFound || body["code"] != "USER_NOT_FOUND" ||
+		body["title"] != "Usuario no encontrado" || body["detail"] != "no existe un usuario con ID 0b6f5b8e-3f5a-4c2e-9d8a-1a2b3c4d5e6f" {
+		t.Fatalf("not found in Spanish: status %d, body %v", resp.StatusCode, body)
+	}
+
+	resp, body = send(http.MethodPost, "/users", "es", `{"first_name":"","last_name":"Gomez","email":"bad","phone":"+541145551234"}`)
+	errs, _ := body["errors"].(map[string]interface{})
+	if resp.StatusCode != http.StatusBadRequest || body["code"] != "VALIDATION_FAILED" || fmt.Sprint(errs["email"]) != "[debe ser un email válido]" {
+		t.Fatalf("validation in Spanish: status %d, body %v", resp.StatusCode, body)
+	}
+
+	// Idioma sin catálogo: inglés
+	resp, body = send(http.MethodGet, missing, "fr-FR,de;q=0.5", "")
+	if resp.Header.Get("Content-Language") != "en" || body["title"] != "User not found" {
+		t.Fatalf("fallback: Content-Language %q, body %v", resp.Header.Get("Content-Language"), body)
+	}
+
+	// El catálogo de errores también se traduce
+	_, body = send(http.MethodGet, "/errors", "es", "")
+	entries, _ := body["data"].([]interface{})
+	titles := make(map[string]interface{})
+	for _, entry := range entries {
+		entry := entry.(map[string]interface{})
+		titles[entry["code"].(string)] = entry["title"]
+	}
+	if body["message"] != "Catálogo de errores obtenido correctamente" || titles["USER_EMAIL_TAKEN"] != "El email ya está en uso" {
+		t.Fatalf("GET /errors in Spanish: %v", body)
+	}
+}